@@ -14,7 +14,10 @@ import (
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/grassrootseconomics/ens-offchain-resolver/internal/api"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/namegen"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/policy"
 	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/sweeper"
 	"github.com/grassrootseconomics/ens-offchain-resolver/internal/util"
 	"github.com/grassrootseconomics/ens-offchain-resolver/pkg/ens"
 	"github.com/knadh/koanf/v2"
@@ -61,34 +64,130 @@ func main() {
 		os.Exit(1)
 	}
 
+	leases := make(map[string]time.Duration)
+	for _, kind := range ko.MapKeys("expiry.lease") {
+		leases[kind] = ko.Duration("expiry.lease." + kind)
+	}
+
 	store, err := store.NewPgStore(store.PgOpts{
 		Logg:                 lo,
 		DSN:                  ko.MustString("postgres.dsn"),
 		MigrationsFolderPath: migrationsFolderFlag,
 		QueriesFolderPath:    queriesFlag,
+		RenameGracePeriod:    ko.Duration("names.rename_grace_period"),
+		RenameCooldown:       ko.Duration("names.rename_cooldown"),
+		Leases:               leases,
+		ExpiryGracePeriod:    ko.Duration("expiry.grace_period"),
 	})
 	if err != nil {
 		lo.Error("could not initialize postgres store", "error", err)
 		os.Exit(1)
 	}
 
-	ensProvider, err := ens.NewProvider(chainSigner, ko.MustString("chain.eth_rpc_url"))
+	ensProvider, err := ens.NewProvider(ens.ProviderOpts{
+		Logg:              lo,
+		SigningKey:        chainSigner,
+		RPCURLs:           util.RPCURLs(lo, ko),
+		Timeout:           ko.Duration("chain.timeout"),
+		CacheTTL:          ko.Duration("chain.cache_ttl"),
+		NegativeCacheTTL:  ko.Duration("chain.negative_cache_ttl"),
+		UniversalResolver: ko.String("chain.universal_resolver"),
+		IPFSGateway:       ko.String("avatar.ipfs_gateway"),
+		MaxAvatarSize:     ko.Int64("avatar.max_size"),
+		AvatarCacheTTL:    ko.Duration("avatar.cache_ttl"),
+		AvatarCacheSize:   ko.Int64("avatar.cache_size"),
+	})
 	if err != nil {
 		lo.Error("could not initialize ENS provider", "error", err)
 		os.Exit(1)
 	}
 
+	autoChooseStrategies, err := namegen.NewChain(ko.Strings("api.autochoose_strategies"))
+	if err != nil {
+		lo.Error("could not load autochoose strategies", "error", err)
+		os.Exit(1)
+	}
+
+	policyRules := []policy.Rule{}
+	for _, r := range ko.Slices("policy.rules") {
+		policyRules = append(policyRules, policy.Rule{
+			Name:        r.String("name"),
+			Pattern:     r.MustString("pattern"),
+			Description: r.String("description"),
+		})
+	}
+
+	namePolicy, err := policy.New(policy.Opts{
+		MinLength:  ko.Int("policy.min_length"),
+		MaxLength:  ko.Int("policy.max_length"),
+		Reserved:   ko.Strings("policy.reserved"),
+		Blocked:    ko.Strings("policy.blocked"),
+		Blocklists: ko.Strings("policy.blocklists"),
+		Rules:      policyRules,
+	})
+	if err != nil {
+		lo.Error("could not load name policy", "error", err)
+		os.Exit(1)
+	}
+
+	trustedProxies, err := api.ParseTrustedProxies(ko.Strings("ratelimit.trusted_proxies"))
+	if err != nil {
+		lo.Error("could not parse trusted proxies", "error", err)
+		os.Exit(1)
+	}
+
+	rateLimits := make(map[string]api.RateLimitOpts)
+	for _, group := range ko.MapKeys("ratelimit") {
+		if group == "trusted_proxies" {
+			continue
+		}
+		rateLimits[group] = api.RateLimitOpts{
+			RPS:   ko.Float64("ratelimit." + group + ".rps"),
+			Burst: ko.Int("ratelimit." + group + ".burst"),
+		}
+	}
+
 	apiServer := api.New(api.APIOpts{
-		CCIPOnly:      false, // Always false for full service mode
-		VerifyingKey:  publicKey,
-		EnableMetrics: ko.Bool("metrics.enable"),
-		ListenAddress: ko.MustString("api.address"),
-		Store:         store,
-		Logg:          lo,
-		ENSProvider:   ensProvider,
-		CORS:          ko.Strings("api.cors"),
+		CCIPOnly:             false, // Always false for full service mode
+		VerifyingKey:         publicKey,
+		JWTIssuers:           ko.Strings("api.jwt_issuers"),
+		JWTAudience:          ko.String("api.jwt_audience"),
+		EnableMetrics:        ko.Bool("metrics.enable"),
+		ListenAddress:        ko.MustString("api.address"),
+		Store:                store,
+		Logg:                 lo,
+		ENSProvider:          ensProvider,
+		CORS:                 ko.Strings("api.cors"),
+		TrustedProxies:       trustedProxies,
+		RateLimits:           rateLimits,
+		IdempotencyWindow:    ko.Duration("api.idempotency_window"),
+		AutoChooseStrategies: autoChooseStrategies,
+		ReservationTTL:       ko.Duration("api.reservation_ttl"),
+		Policy:               namePolicy,
+		TransferTTL:          ko.Duration("transfers.ttl"),
+		BatchLimit:           ko.Int("api.batch_limit"),
+		BulkLimit:            ko.Int("api.bulk_limit"),
+		AvatarCacheTTL:       ko.Duration("avatar.cache_ttl"),
+		SIWEDomain:           ko.MustString("siwe.domain"),
+		SIWEURI:              ko.MustString("siwe.uri"),
+		SIWEChainID:          ko.MustInt64("siwe.chain_id"),
 	})
 
+	sweeper := sweeper.New(sweeper.SweeperOpts{
+		Logg:     lo,
+		Store:    store,
+		Interval: ko.Duration("sweeper.interval"),
+	})
+	sweeper.AddJob("policy reload", apiServer.ReloadPolicy)
+	sweeper.AddJob("RPC health check", ensProvider.CheckHealth)
+	sweeper.AddJob("expired idempotency keys", apiServer.PurgeIdempotencyKeys)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sweeper.Start()
+	}()
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -110,6 +209,14 @@ func main() {
 		}
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := sweeper.Stop(shutdownCtx); err != nil {
+			lo.Error("failed to stop sweeper", "err", fmt.Sprintf("%T", err))
+		}
+	}()
+
 	go func() {
 		wg.Wait()
 		stop()
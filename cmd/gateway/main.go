@@ -66,27 +66,57 @@ func main() {
 		DSN:                  ko.MustString("postgres.dsn"),
 		MigrationsFolderPath: migrationsFolderFlag,
 		QueriesFolderPath:    queriesFlag,
+		ExpiryGracePeriod:    ko.Duration("expiry.grace_period"),
 	})
 	if err != nil {
 		lo.Error("could not initialize postgres store", "error", err)
 		os.Exit(1)
 	}
 
-	ensProvider, err := ens.NewProvider(chainSigner, ko.MustString("chain.eth_rpc_url"))
+	ensProvider, err := ens.NewProvider(ens.ProviderOpts{
+		Logg:              lo,
+		SigningKey:        chainSigner,
+		RPCURLs:           util.RPCURLs(lo, ko),
+		Timeout:           ko.Duration("chain.timeout"),
+		CacheTTL:          ko.Duration("chain.cache_ttl"),
+		NegativeCacheTTL:  ko.Duration("chain.negative_cache_ttl"),
+		UniversalResolver: ko.String("chain.universal_resolver"),
+	})
 	if err != nil {
 		lo.Error("could not initialize ENS provider", "error", err)
 		os.Exit(1)
 	}
 
+	trustedProxies, err := api.ParseTrustedProxies(ko.Strings("ratelimit.trusted_proxies"))
+	if err != nil {
+		lo.Error("could not parse trusted proxies", "error", err)
+		os.Exit(1)
+	}
+
+	rateLimits := make(map[string]api.RateLimitOpts)
+	for _, group := range ko.MapKeys("ratelimit") {
+		if group == "trusted_proxies" {
+			continue
+		}
+		rateLimits[group] = api.RateLimitOpts{
+			RPS:   ko.Float64("ratelimit." + group + ".rps"),
+			Burst: ko.Int("ratelimit." + group + ".burst"),
+		}
+	}
+
 	apiServer := api.New(api.APIOpts{
-		CCIPOnly:      true, // Always true for gateway mode
-		VerifyingKey:  publicKey,
-		EnableMetrics: ko.Bool("metrics.enable"),
-		ListenAddress: ko.MustString("api.address"),
-		Store:         store,
-		Logg:          lo,
-		ENSProvider:   ensProvider,
-		CORS:          ko.Strings("api.cors"),
+		CCIPOnly:       true, // Always true for gateway mode
+		VerifyingKey:   publicKey,
+		JWTIssuers:     ko.Strings("api.jwt_issuers"),
+		JWTAudience:    ko.String("api.jwt_audience"),
+		EnableMetrics:  ko.Bool("metrics.enable"),
+		ListenAddress:  ko.MustString("api.address"),
+		Store:          store,
+		Logg:           lo,
+		ENSProvider:    ensProvider,
+		CORS:           ko.Strings("api.cors"),
+		TrustedProxies: trustedProxies,
+		RateLimits:     rateLimits,
 	})
 
 	wg.Add(1)
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/api"
+)
+
+const defaultImportChunk = 500
+
+// runImport sends the entries of a CSV or JSONL file to the bulk registration endpoint in chunks and writes a
+// combined CSV report.
+//
+// CSV files need a header with address and hint (or name) columns, an optional kind column and any other
+// column is stored as a text record. JSONL lines are register requests, e.g.
+// {"address":"0x...","hint":"peter","texts":{"location":"Nairobi"}}.
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	c := newClient(fs)
+	format := fs.String("format", "", "Input format, csv or jsonl, defaults to the file extension")
+	mode := fs.String("mode", "per_row", "per_row registers what it can, atomic registers all entries or none")
+	kind := fs.String("kind", "", "Kind of entries without one, defaults to user")
+	chunk := fs.Int("chunk", defaultImportChunk, "Entries per request, at most the server's api.bulk_limit")
+	reportPath := fs.String("report", "-", "Report file, - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: resolverctl import [flags] <file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var entries []api.RegisterRequest
+	switch *format {
+	case "csv":
+		entries, err = readCSVEntries(f)
+	case "jsonl":
+		entries, err = readJSONLEntries(f)
+	default:
+		return fmt.Errorf("unsupported format %q, use csv or jsonl", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].Kind == "" {
+			entries[i].Kind = *kind
+		}
+	}
+
+	if *chunk <= 0 {
+		return errors.New("chunk must be positive")
+	}
+	if *mode == "atomic" && len(entries) > *chunk {
+		return fmt.Errorf("atomic imports must fit in a single request, %d entries exceed a chunk of %d", len(entries), *chunk)
+	}
+
+	report := api.BulkReport{Mode: *mode}
+	for start := 0; start < len(entries); start += *chunk {
+		end := min(start+*chunk, len(entries))
+
+		var result struct {
+			Report api.BulkReport `json:"report"`
+		}
+		err := c.post(ctx, "/internal/register/bulk", api.BulkRegisterRequest{
+			Mode:    *mode,
+			Entries: entries[start:end],
+		}, &result)
+		if err != nil {
+			return fmt.Errorf("entries %d-%d: %w", start, end-1, err)
+		}
+
+		for _, r := range result.Report.Results {
+			r.Index += start
+			report.Results = append(report.Results, r)
+		}
+		report.Committed = result.Report.Committed
+		report.Registered += result.Report.Registered
+		report.Renamed += result.Report.Renamed
+		report.Existing += result.Report.Existing
+		report.Failed += result.Report.Failed
+
+		lo.Info("chunk imported", "from", start, "to", end-1, "failed", result.Report.Failed)
+	}
+
+	if err := writeReport(*reportPath, report); err != nil {
+		return err
+	}
+
+	lo.Info("import finished",
+		"entries", len(entries),
+		"committed", report.Committed,
+		"registered", report.Registered,
+		"renamed", report.Renamed,
+		"existing", report.Existing,
+		"failed", report.Failed,
+	)
+
+	return nil
+}
+
+func readCSVEntries(r io.Reader) ([]api.RegisterRequest, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["hint"]; !ok {
+		if i, ok := columns["name"]; ok {
+			columns["hint"] = i
+			delete(columns, "name")
+		}
+	}
+	for _, required := range []string{"address", "hint"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	var entries []api.RegisterRequest
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := api.RegisterRequest{}
+		for column, i := range columns {
+			value := strings.TrimSpace(record[i])
+			switch column {
+			case "address":
+				entry.Address = value
+			case "hint":
+				entry.Hint = value
+			case "kind":
+				entry.Kind = value
+			default:
+				if value == "" {
+					continue
+				}
+				if entry.Texts == nil {
+					entry.Texts = make(map[string]string)
+				}
+				entry.Texts[column] = value
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func readJSONLEntries(r io.Reader) ([]api.RegisterRequest, error) {
+	var entries []api.RegisterRequest
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var entry api.RegisterRequest
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func writeReport(path string, report api.BulkReport) error {
+	out := os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	cw := csv.NewWriter(out)
+	cw.Write([]string{"index", "address", "hint", "name", "status", "error"})
+	for _, r := range report.Results {
+		cw.Write([]string{strconv.Itoa(r.Index), r.Address, r.Hint, r.Name, r.Status, r.Error})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/util"
+)
+
+const defaultRequestTimeout = time.Minute * 5
+
+type (
+	// client calls the internal API of a running full service.
+	client struct {
+		baseURL    string
+		apiKey     string
+		token      string
+		httpClient *http.Client
+	}
+
+	apiResponse[T any] struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      T      `json:"result"`
+	}
+)
+
+var (
+	build = "dev"
+
+	lo *slog.Logger
+)
+
+func main() {
+	lo = util.InitLogger()
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(ctx, os.Args[2:])
+	case "export":
+		err = runExport(ctx, os.Args[2:])
+	case "restore":
+		err = runRestore(ctx, os.Args[2:])
+	case "version":
+		fmt.Println(build)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		lo.Error("command failed", "command", os.Args[1], "error", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: resolverctl <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  import    bulk register names from a CSV or JSONL file")
+	fmt.Fprintln(os.Stderr, "  export    download a snapshot of all names as JSONL or CSV")
+	fmt.Fprintln(os.Stderr, "  restore   load a snapshot into an empty store")
+	fmt.Fprintln(os.Stderr, "  version   print the build version")
+}
+
+// newClient registers the connection flags shared by all commands.
+func newClient(fs *flag.FlagSet) *client {
+	c := &client{
+		httpClient: &http.Client{Timeout: defaultRequestTimeout},
+	}
+	fs.StringVar(&c.baseURL, "url", "http://localhost:5015", "Resolver base URL")
+	fs.StringVar(&c.apiKey, "api-key", os.Getenv("RESOLVER_API_KEY"), "API key, defaults to $RESOLVER_API_KEY")
+	fs.StringVar(&c.token, "token", os.Getenv("RESOLVER_TOKEN"), "Service JWT, defaults to $RESOLVER_TOKEN")
+
+	return c
+}
+
+func (c *client) post(ctx context.Context, path string, body any, result any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	res := apiResponse[json.RawMessage]{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("%s: %w", resp.Status, err)
+	}
+	if !res.Ok {
+		return fmt.Errorf("%s: %s", resp.Status, res.Description)
+	}
+
+	return json.Unmarshal(res.Result, result)
+}
+
+// download copies a non JSON response body to out.
+func (c *client) download(ctx context.Context, path string, out io.Writer) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		res := apiResponse[json.RawMessage]{}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return fmt.Errorf("%s: %w", resp.Status, err)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, res.Description)
+	}
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+func (c *client) do(ctx context.Context, method string, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.baseURL, "/")+"/api/v1"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	return c.httpClient.Do(req)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/snapshot"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/util"
+)
+
+// runExport downloads a consistent snapshot of all names through the admin export endpoint.
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	c := newClient(fs)
+	format := fs.String("format", snapshot.FormatJSONL, "Snapshot format, jsonl or csv")
+	outPath := fs.String("out", "-", "Snapshot file, - for stdout")
+	fs.Parse(args)
+
+	// Snapshots of large registries take longer than a regular request.
+	c.httpClient.Timeout = 0
+
+	out := os.Stdout
+	if *outPath != "-" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := c.download(ctx, "/internal/admin/export?format="+*format, out); err != nil {
+		return err
+	}
+
+	if *outPath != "-" {
+		lo.Info("snapshot exported", "file", *outPath)
+	}
+
+	return nil
+}
+
+// runRestore loads a snapshot straight into the store configured in the config file, which must not hold any
+// names yet. Migrations are run first so that a fresh database can be restored into.
+func runRestore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	confPath := fs.String("config", "config.toml", "Config file location")
+	migrationsPath := fs.String("migrations", "migrations/", "Migrations folder location")
+	queriesPath := fs.String("queries", "queries.sql", "Queries file location")
+	format := fs.String("format", "", "Snapshot format, jsonl or csv, defaults to the file extension")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: resolverctl restore [flags] <file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	names, err := snapshot.Read(f, *format)
+	if err != nil {
+		return err
+	}
+
+	ko := util.InitConfig(lo, *confPath)
+	st, err := store.NewPgStore(store.PgOpts{
+		Logg:                 lo,
+		DSN:                  ko.MustString("postgres.dsn"),
+		MigrationsFolderPath: *migrationsPath,
+		QueriesFolderPath:    *queriesPath,
+	})
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	if err := st.RestoreNames(ctx, names); err != nil {
+		return err
+	}
+	lo.Info("snapshot restored", "names", len(names))
+
+	return nil
+}
@@ -19,6 +19,7 @@ require (
 	github.com/rs/cors v1.7.0
 	github.com/uptrace/bunrouter v1.0.23
 	github.com/uptrace/bunrouter/extra/reqlog v1.0.23
+	golang.org/x/time v0.12.0
 )
 
 require (
@@ -89,6 +90,5 @@ require (
 	golang.org/x/sync v0.15.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
-	golang.org/x/time v0.12.0 // indirect
 	lukechampine.com/blake3 v1.4.1 // indirect
 )
@@ -5,8 +5,13 @@ import (
 	"crypto"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/namegen"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/policy"
 	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
 	"github.com/grassrootseconomics/ens-offchain-resolver/pkg/ens"
 	"github.com/kamikazechaser/common/httputil"
@@ -16,25 +21,63 @@ import (
 
 type (
 	APIOpts struct {
-		CCIPOnly      bool
-		EnableMetrics bool
-		ListenAddress string
-		ETHRPCURL     string
-		VerifyingKey  crypto.PublicKey
-		Store         store.Store
-		Logg          *slog.Logger
-		ENSProvider   *ens.ENS
-		CORS          []string
+		CCIPOnly       bool
+		EnableMetrics  bool
+		ListenAddress  string
+		ETHRPCURL      string
+		VerifyingKey   crypto.PublicKey
+		JWTIssuers     []string
+		JWTAudience    string
+		Store          store.Store
+		Logg           *slog.Logger
+		ENSProvider    *ens.ENS
+		CORS           []string
+		SIWEDomain     string
+		SIWEURI        string
+		SIWEChainID    int64
+		TrustedProxies []netip.Prefix
+		RateLimits     map[string]RateLimitOpts
+		// IdempotencyWindow is how long register and upsert responses are replayed, defaults to 24h.
+		IdempotencyWindow time.Duration
+		// AutoChooseStrategies are tried in order when a hint is taken, defaults to random then sequential.
+		AutoChooseStrategies []namegen.Strategy
+		// ReservationTTL is how long a name reservation is held by default, defaults to 15m.
+		ReservationTTL time.Duration
+		// Policy decides which names may be registered, defaults to policy.Default.
+		Policy *policy.Policy
+		// TransferTTL is how long a transfer waits for the recipient to accept it.
+		TransferTTL time.Duration
+		// BatchLimit caps the items of a batch resolve request, defaults to 100.
+		BatchLimit int
+		// BulkLimit caps the entries of a bulk registration request, defaults to 1000.
+		BulkLimit int
+		// AvatarCacheTTL is how long clients may cache avatar images, defaults to 1h.
+		AvatarCacheTTL time.Duration
 	}
 
 	API struct {
-		validator    httputil.ValidatorProvider
-		verifyingKey crypto.PublicKey
-		store        store.Store
-		router       *bunrouter.Router
-		server       *http.Server
-		logg         *slog.Logger
-		ensProvider  *ens.ENS
+		validator            httputil.ValidatorProvider
+		verifyingKey         crypto.PublicKey
+		jwtParser            *jwt.Parser
+		jwtIssuers           []string
+		store                store.Store
+		router               *bunrouter.Router
+		server               *http.Server
+		logg                 *slog.Logger
+		ensProvider          *ens.ENS
+		siweDomain           string
+		siweURI              string
+		siweChainID          int64
+		trustedProxies       []netip.Prefix
+		idempotencyWindow    time.Duration
+		autoChooseStrategies []namegen.Strategy
+		policy               *policy.Policy
+		reservationTTL       time.Duration
+		transferTTL          time.Duration
+		batchLimit           int
+		batchLimiter         *rateLimiter
+		bulkLimit            int
+		avatarCacheTTL       time.Duration
 	}
 )
 
@@ -44,13 +87,64 @@ func New(o APIOpts) *API {
 	api := &API{
 		validator:    httputil.NewValidator(""),
 		verifyingKey: o.VerifyingKey,
+		jwtParser:    newJWTParser(o.JWTAudience),
+		jwtIssuers:   o.JWTIssuers,
 		logg:         o.Logg,
 		store:        o.Store,
 		router: bunrouter.New(
 			bunrouter.WithNotFoundHandler(notFoundHandler),
 			bunrouter.WithMethodNotAllowedHandler(methodNotAllowedHandler),
 		),
-		ensProvider: o.ENSProvider,
+		ensProvider:          o.ENSProvider,
+		siweDomain:           o.SIWEDomain,
+		siweURI:              o.SIWEURI,
+		siweChainID:          o.SIWEChainID,
+		trustedProxies:       o.TrustedProxies,
+		idempotencyWindow:    o.IdempotencyWindow,
+		autoChooseStrategies: o.AutoChooseStrategies,
+		policy:               o.Policy,
+		reservationTTL:       o.ReservationTTL,
+		transferTTL:          o.TransferTTL,
+		batchLimit:           o.BatchLimit,
+		bulkLimit:            o.BulkLimit,
+		avatarCacheTTL:       o.AvatarCacheTTL,
+	}
+
+	if api.idempotencyWindow <= 0 {
+		api.idempotencyWindow = defaultIdempotencyWindow
+	}
+
+	if api.policy == nil {
+		api.policy = policy.Default()
+	}
+
+	if api.reservationTTL <= 0 {
+		api.reservationTTL = defaultReservationTTL
+	}
+
+	if api.transferTTL <= 0 {
+		api.transferTTL = defaultTransferTTL
+	}
+
+	if api.batchLimit <= 0 {
+		api.batchLimit = defaultBatchLimit
+	}
+
+	// The bucket has to hold a full batch, or large batches could never pass.
+	batchRateLimit := o.RateLimits[rateLimitGroupBatch]
+	batchRateLimit.Burst = max(batchRateLimit.Burst, api.batchLimit)
+	api.batchLimiter = newRateLimiter(rateLimitGroupBatch, batchRateLimit)
+
+	if api.bulkLimit <= 0 {
+		api.bulkLimit = defaultBulkLimit
+	}
+
+	if api.avatarCacheTTL <= 0 {
+		api.avatarCacheTTL = defaultAvatarCacheTTL
+	}
+
+	if len(api.autoChooseStrategies) == 0 {
+		api.autoChooseStrategies, _ = namegen.NewChain(defaultAutoChooseStrategies)
 	}
 
 	if o.EnableMetrics {
@@ -64,19 +158,78 @@ func New(o APIOpts) *API {
 
 		if o.CCIPOnly {
 			o.Logg.Info("CCIP read gateway mode only")
-			g.GET("/:sender/*data", api.ccipHandler)
+			g.Use(api.rateLimitMiddleware(
+				newRateLimiter(rateLimitGroupCCIP, o.RateLimits[rateLimitGroupCCIP]),
+				api.clientIPKey,
+			)).GET("/:sender/*data", api.ccipHandler)
 		} else {
 			g.WithGroup("/resolve", func(rG *bunrouter.Group) {
+				rG = rG.Use(api.rateLimitMiddleware(
+					newRateLimiter(rateLimitGroupResolve, o.RateLimits[rateLimitGroupResolve]),
+					api.clientIPKey,
+				))
 				rG.GET("/:name", api.resolveHandler)
+				rG.GET("/:name/details", api.nameDetailsHandler)
+				rG.GET("/:name/avatar", api.avatarHandler)
 				rG.GET("/reverse/:address", api.reverseResolveHandler)
+				// Batches are also charged per item to the batch group by their handlers.
+				rG.POST("/batch", api.batchResolveHandler)
+				rG.POST("/reverse/batch", api.batchReverseResolveHandler)
+			})
+
+			g.WithGroup("/self", func(rG *bunrouter.Group) {
+				rG = rG.Use(api.rateLimitMiddleware(
+					newRateLimiter(rateLimitGroupSelf, o.RateLimits[rateLimitGroupSelf]),
+					api.clientIPKey,
+				))
+				rG.POST("/challenge", api.selfChallengeHandler)
+				rG.POST("/confirm", api.selfConfirmHandler)
 			})
 
 			g.WithGroup("/internal", func(rG *bunrouter.Group) {
-				rG = rG.Use(api.authMiddleware)
-				rG.POST("/register", api.registerHandler)
-				rG.PUT("/update", api.updateHandler)
-				rG.POST("/upsert", api.upsertHandler)
+				// Invalid tokens and API keys are throttled by client IP before they reach the store.
+				rG = rG.Use(api.rateLimitMiddleware(
+					newRateLimiter(rateLimitGroupAuth, o.RateLimits[rateLimitGroupAuth]),
+					api.clientIPKey,
+				), api.authMiddleware, api.rateLimitMiddleware(
+					newRateLimiter(rateLimitGroupInternal, o.RateLimits[rateLimitGroupInternal]),
+					subjectKey,
+				))
+
+				roG := rG.Use(api.requireScope(scopeNamesRead))
+				roG.GET("/available/:name", api.availableHandler)
+				roG.GET("/address/:address/names", api.listAddressNamesHandler)
+				roG.GET("/names", api.listNamesHandler)
+				roG.GET("/names/:name/history", api.nameHistoryHandler)
+				roG.GET("/transfers/:id", api.getTransferHandler)
+
+				wG := rG.Use(api.requireScope(scopeNamesWrite))
+				wG.PUT("/update", api.updateHandler)
+				wG.PUT("/primary", api.setPrimaryNameHandler)
+				wG.PUT("/address", api.setNameAddressHandler)
+				wG.POST("/renew", api.renewHandler)
+				wG.POST("/transfers", api.createTransferHandler)
+				wG.POST("/transfers/:id/accept", api.acceptTransferHandler)
+				wG.DELETE("/transfers/:id", api.cancelTransferHandler)
+				wG.POST("/reserve", api.reserveHandler)
+				// Bulk bodies exceed what idempotencyMiddleware buffers, reruns are safe as existing entries are skipped.
+				wG.POST("/register/bulk", api.bulkRegisterHandler)
+				wG.DELETE("/reserve/:token", api.releaseReservationHandler)
+
+				iG := wG.Use(api.idempotencyMiddleware)
+				iG.POST("/register", api.registerHandler)
+				iG.POST("/upsert", api.upsertHandler)
 
+				rG.WithGroup("/admin", func(aG *bunrouter.Group) {
+					aG = aG.Use(api.requireScope(scopeAdmin))
+					aG.POST("/keys", api.createAPIKeyHandler)
+					aG.GET("/keys", api.listAPIKeysHandler)
+					aG.DELETE("/keys/:id", api.revokeAPIKeyHandler)
+					aG.GET("/policy", api.listPolicyEntriesHandler)
+					aG.GET("/export", api.exportHandler)
+					aG.POST("/policy", api.addPolicyEntryHandler)
+					aG.DELETE("/policy/:id", api.deletePolicyEntryHandler)
+				})
 			})
 		}
 	})
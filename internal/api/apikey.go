@@ -0,0 +1,139 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyPrefix = "gek_"
+)
+
+func (a *API) createAPIKeyHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var createReq CreateAPIKeyRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &createReq); err != nil {
+		a.logg.Error("validation failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	if createReq.ExpiresAt != nil && createReq.ExpiresAt.Before(time.Now()) {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Expiry must be in the future",
+		})
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		a.logg.Error("API key generation failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	apiKey := store.APIKey{
+		Name:      createReq.Name,
+		Scopes:    createReq.Scopes,
+		Active:    true,
+		ExpiresAt: createReq.ExpiresAt,
+	}
+	if err := a.store.CreateAPIKey(req.Context(), &apiKey, hashAPIKey(key)); err != nil {
+		a.logg.Error("create API key failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	// The plaintext key is only ever returned here.
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "API key created",
+		Result: map[string]any{
+			"key":    key,
+			"apiKey": apiKey,
+		},
+	})
+}
+
+func (a *API) listAPIKeysHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	apiKeys, err := a.store.ListAPIKeys(req.Context())
+	if err != nil {
+		a.logg.Error("list API keys failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "API keys",
+		Result: map[string]any{
+			"apiKeys": apiKeys,
+		},
+	})
+}
+
+func (a *API) revokeAPIKeyHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	id, err := strconv.Atoi(req.Param("id"))
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid API key id",
+		})
+	}
+
+	revoked, err := a.store.RevokeAPIKey(req.Context(), id)
+	if err != nil {
+		a.logg.Error("revoke API key failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	if !revoked {
+		return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+			Ok:          false,
+			Description: "API key not found",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "API key revoked",
+		Result: map[string]any{
+			"id": id,
+		},
+	})
+}
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// hashAPIKey is a plain SHA-256, the keys are high entropy so a slow KDF adds nothing.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,62 +1,128 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang-jwt/jwt/v5/request"
+	"github.com/jackc/pgx/v5"
 	"github.com/kamikazechaser/common/httputil"
 	"github.com/uptrace/bunrouter"
 )
 
-type JWTCustomClaims struct {
-	PublicKey string `json:"publicKey"`
-	Service   bool   `json:"service"`
-	jwt.RegisteredClaims
+type (
+	JWTCustomClaims struct {
+		PublicKey string `json:"publicKey"`
+		Service   bool   `json:"service"`
+		// Scope lists space separated scopes, tokens without it get defaultServiceScopes.
+		Scope string `json:"scope"`
+		jwt.RegisteredClaims
+	}
+
+	// principal is the authenticated caller of an internal route.
+	principal struct {
+		Subject string
+		Scopes  []string
+	}
+
+	principalKey struct{}
+)
+
+const (
+	scopeNamesRead  = "names:read"
+	scopeNamesWrite = "names:write"
+	scopeAdmin      = "admin"
+)
+
+var (
+	errInvalidIssuer = errors.New("issuer not allowed")
+
+	// Service level JWTs can manage names, admin has to be granted explicitly through the scope claim.
+	defaultServiceScopes = []string{scopeNamesRead, scopeNamesWrite}
+	knownScopes          = []string{scopeNamesRead, scopeNamesWrite, scopeAdmin}
+)
+
+// scopes returns the known scopes of the scope claim, unknown ones are ignored.
+func (c *JWTCustomClaims) scopes() []string {
+	if c.Scope == "" {
+		return defaultServiceScopes
+	}
+
+	var scopes []string
+	for _, s := range strings.Fields(c.Scope) {
+		if slices.Contains(knownScopes, s) && !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func newJWTParser(audience string) *jwt.Parser {
+	var opts []jwt.ParserOption
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	return jwt.NewParser(opts...)
 }
 
 func (a *API) authMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		if key := req.Header.Get(apiKeyHeader); key != "" {
+			return a.apiKeyAuth(next, key, w, req)
+		}
+
 		if h := req.Header.Get("Authorization"); h != "" {
 			token, err := request.ParseFromRequest(req.Request, request.AuthorizationHeaderExtractor, func(t *jwt.Token) (interface{}, error) {
 				if t.Method.Alg() != jwt.SigningMethodEdDSA.Alg() {
 					return nil, jwt.ErrTokenUnverifiable
 				}
 				return a.verifyingKey, nil
-			}, request.WithClaims(&JWTCustomClaims{}))
+			}, request.WithClaims(&JWTCustomClaims{}), request.WithParser(a.jwtParser))
+
+			if err == nil && !token.Valid {
+				err = jwt.ErrTokenInvalidClaims
+			}
+
+			var claims *JWTCustomClaims
+			if err == nil {
+				claims = token.Claims.(*JWTCustomClaims)
+				if !a.isAllowedIssuer(claims.Issuer) {
+					err = errInvalidIssuer
+				}
+			}
 
 			if err != nil {
 				a.logg.Error("JWT validation failed", "error", err)
-				return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+				return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
 					Ok:          false,
-					Description: "JWT validation failed",
+					Description: jwtErrorDescription(err),
 				})
 			}
 
-			if !token.Valid {
+			if claims.Subject == "sarafu-network" || claims.Subject == "sn-prod" || claims.Subject == "ussd-prod" {
 				return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
 					Ok:          false,
-					Description: "Invalid token",
+					Description: "Token has been revoked",
 				})
 			}
 
-			if claims, ok := token.Claims.(JWTCustomClaims); ok {
-				if claims.Subject == "sarafu-network" || claims.Subject == "sn-prod" || claims.Subject == "ussd-prod" {
-					return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
-						Ok:          false,
-						Description: "Token has been revoked",
-					})
-				}
-
-				if !claims.Service {
-					return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
-						Ok:          false,
-						Description: "Only service level keys allowed",
-					})
-				}
+			if !claims.Service {
+				return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
+					Ok:          false,
+					Description: "Only service level keys allowed",
+				})
 			}
 
-			return next(w, req)
+			return next(w, req.WithContext(context.WithValue(req.Context(), principalKey{}, &principal{
+				Subject: claims.Subject,
+				Scopes:  claims.scopes(),
+			})))
 		} else {
 			return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
 				Ok:          false,
@@ -65,3 +131,88 @@ func (a *API) authMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 		}
 	}
 }
+
+func (a *API) apiKeyAuth(next bunrouter.HandlerFunc, key string, w http.ResponseWriter, req bunrouter.Request) error {
+	apiKey, err := a.store.UseAPIKey(req.Context(), hashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
+				Ok:          false,
+				Description: "Invalid, expired or revoked API key",
+			})
+		}
+
+		a.logg.Error("API key lookup failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return next(w, req.WithContext(context.WithValue(req.Context(), principalKey{}, &principal{
+		Subject: "apikey:" + strconv.Itoa(apiKey.ID),
+		Scopes:  apiKey.Scopes,
+	})))
+}
+
+// requireScope must be used after authMiddleware.
+func (a *API) requireScope(scope string) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			p := principalFromContext(req.Context())
+			if p == nil || !slices.Contains(p.Scopes, scope) {
+				return httputil.JSON(w, http.StatusForbidden, ErrResponse{
+					Ok:          false,
+					Description: "Missing required scope: " + scope,
+				})
+			}
+
+			return next(w, req)
+		}
+	}
+}
+
+func principalFromContext(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalKey{}).(*principal)
+	return p
+}
+
+// tenantFromContext returns the subject that names registered by the request are attributed to.
+func tenantFromContext(ctx context.Context) string {
+	if p := principalFromContext(ctx); p != nil {
+		return p.Subject
+	}
+	return ""
+}
+
+// isAllowedIssuer accepts any issuer when no allowlist is configured.
+func (a *API) isAllowedIssuer(issuer string) bool {
+	if len(a.jwtIssuers) == 0 {
+		return true
+	}
+
+	return slices.Contains(a.jwtIssuers, issuer)
+}
+
+func jwtErrorDescription(err error) string {
+	switch {
+	case errors.Is(err, request.ErrNoTokenInRequest):
+		return "Authorization token is required"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "Malformed token"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return "Invalid token signature"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "Token has expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "Token is not valid yet"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "Token is missing a required claim"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "Token audience is not accepted"
+	case errors.Is(err, errInvalidIssuer), errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "Token issuer is not accepted"
+	default:
+		return "Invalid token"
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &API{
+		verifyingKey: pub,
+		jwtParser:    newJWTParser("ens-resolver"),
+		jwtIssuers:   []string{"eth-custodial"},
+		logg:         slog.New(slog.DiscardHandler),
+	}
+
+	sign := func(iss string, aud string) string {
+		claims := JWTCustomClaims{
+			Service: true,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    iss,
+				Subject:   "test",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+		if aud != "" {
+			claims.Audience = jwt.ClaimStrings{aud}
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{
+			name:     "allowed issuer and audience",
+			token:    sign("eth-custodial", "ens-resolver"),
+			expected: http.StatusOK,
+		},
+		{
+			name:     "unknown issuer",
+			token:    sign("other-service", "ens-resolver"),
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong audience",
+			token:    sign("eth-custodial", "other-service"),
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "missing audience",
+			token:    sign("eth-custodial", ""),
+			expected: http.StatusUnauthorized,
+		},
+	}
+
+	handler := a.authMiddleware(func(w http.ResponseWriter, req bunrouter.Request) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/internal/register", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+
+			if err := handler(rec, bunrouter.NewRequest(req)); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.expected {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	f := newFakeStore()
+	a := newTestAPI(f)
+
+	expired := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	keys := map[string]store.APIKey{
+		"gek_reader":  {ID: 1, Scopes: []string{scopeNamesRead}, Active: true, ExpiresAt: &future},
+		"gek_writer":  {ID: 2, Scopes: []string{scopeNamesRead, scopeNamesWrite}, Active: true},
+		"gek_revoked": {ID: 3, Scopes: []string{scopeNamesWrite}, Active: false},
+		"gek_expired": {ID: 4, Scopes: []string{scopeNamesWrite}, Active: true, ExpiresAt: &expired},
+	}
+	for key, apiKey := range keys {
+		f.apiKeys[hashAPIKey(key)] = &apiKey
+	}
+
+	var subject string
+	handler := a.authMiddleware(a.requireScope(scopeNamesWrite)(func(w http.ResponseWriter, req bunrouter.Request) error {
+		subject = principalFromContext(req.Context()).Subject
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}))
+
+	tests := []struct {
+		name     string
+		key      string
+		expected int
+		subject  string
+	}{
+		{name: "scoped key", key: "gek_writer", expected: http.StatusOK, subject: "apikey:2"},
+		{name: "missing scope", key: "gek_reader", expected: http.StatusForbidden},
+		{name: "revoked key", key: "gek_revoked", expected: http.StatusUnauthorized},
+		{name: "expired key", key: "gek_expired", expected: http.StatusUnauthorized},
+		{name: "unknown key", key: "gek_unknown", expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject = ""
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/internal/register", nil)
+			req.Header.Set(apiKeyHeader, tt.key)
+			rec := httptest.NewRecorder()
+
+			if err := handler(rec, bunrouter.NewRequest(req)); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.expected {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expected)
+			}
+			if subject != tt.subject {
+				t.Errorf("subject = %q, want %q", subject, tt.subject)
+			}
+		})
+	}
+}
+
+func TestJWTScopes(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &API{
+		verifyingKey: pub,
+		jwtParser:    newJWTParser(""),
+		logg:         slog.New(slog.DiscardHandler),
+	}
+
+	sign := func(scope string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, JWTCustomClaims{
+			Service: true,
+			Scope:   scope,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "test",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}).SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		scope string
+		want  map[string]int
+	}{
+		{
+			name: "no scope claim",
+			want: map[string]int{scopeNamesWrite: http.StatusOK, scopeAdmin: http.StatusForbidden},
+		},
+		{
+			name:  "read only",
+			scope: "names:read",
+			want:  map[string]int{scopeNamesRead: http.StatusOK, scopeNamesWrite: http.StatusForbidden},
+		},
+		{
+			name:  "admin",
+			scope: "names:read admin unknown",
+			want:  map[string]int{scopeAdmin: http.StatusOK, scopeNamesWrite: http.StatusForbidden},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for scope, expected := range tt.want {
+				handler := a.authMiddleware(a.requireScope(scope)(func(w http.ResponseWriter, req bunrouter.Request) error {
+					w.WriteHeader(http.StatusOK)
+					return nil
+				}))
+
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/internal/admin/keys", nil)
+				req.Header.Set("Authorization", "Bearer "+sign(tt.scope))
+				rec := httptest.NewRecorder()
+				if err := handler(rec, bunrouter.NewRequest(req)); err != nil {
+					t.Fatal(err)
+				}
+				if rec.Code != expected {
+					t.Errorf("%s status = %d, want %d", scope, rec.Code, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/namegen"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/policy"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	defaultSuggestions = 5
+	maxSuggestions     = 20
+)
+
+// availableHandler reports whether a name can be registered and suggests free alternatives without
+// reserving anything. Reserved names count as taken.
+func (a *API) availableHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	count := defaultSuggestions
+	if s := req.URL.Query().Get("suggestions"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxSuggestions {
+			return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+				Ok:          false,
+				Description: "suggestions must be between 0 and " + strconv.Itoa(maxSuggestions),
+			})
+		}
+		count = n
+	}
+
+	kind := req.URL.Query().Get("kind")
+	if kind == "" {
+		kind = kindUser
+	}
+	if _, ok := kinds[kind]; !ok {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Unknown kind",
+		})
+	}
+
+	subdomain, err := parseNameOfKind(req.Param("name"), kind)
+	if err != nil {
+		// Names that cannot be normalized are not echoed back, the result only explains why.
+		return httputil.JSON(w, http.StatusOK, OKResponse{
+			Ok:          true,
+			Description: "Name availability",
+			Result: map[string]any{
+				"name":      "",
+				"available": false,
+				"taken":     false,
+				"violations": []policy.Violation{{
+					Rule:        "format",
+					Description: err.Error(),
+				}},
+				"suggestions": []string{},
+			},
+		})
+	}
+
+	normalizedName := fullName(subdomain, kind)
+	violations := a.policy.Check(subdomain)
+
+	taken, err := a.store.TakenNames(req.Context(), []string{normalizedName})
+	if err != nil {
+		a.logg.Error("taken names lookup failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+	isTaken := len(taken) > 0
+
+	suggestions, err := a.suggestNames(req.Context(), subdomain, kind, count)
+	if err != nil {
+		a.logg.Error("suggest names failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	if violations == nil {
+		violations = []policy.Violation{}
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name availability",
+		Result: map[string]any{
+			"name":        normalizedName,
+			"available":   !isTaken && len(violations) == 0,
+			"taken":       isTaken,
+			"violations":  violations,
+			"suggestions": suggestions,
+		},
+	})
+}
+
+// suggestNames collects up to count free names from the autoChoose strategies, checking each round's
+// candidates in a single query.
+func (a *API) suggestNames(ctx context.Context, subdomain string, kind string, count int) ([]string, error) {
+	suggestions := []string{}
+
+	for round := range autoChooseRounds {
+		if len(suggestions) >= count {
+			break
+		}
+
+		labels := namegen.Generate(a.autoChooseStrategies, subdomain, round, autoChooseBatchSize)
+		if len(labels) == 0 {
+			break
+		}
+
+		candidates := make([]string, 0, len(labels))
+		for _, label := range labels {
+			if len(a.policy.Check(label)) == 0 && (kind != kindUser || !isNamespaceLabel(label)) {
+				candidates = append(candidates, fullName(label, kind))
+			}
+		}
+
+		if len(candidates) == 0 {
+			continue
+		}
+
+		taken, err := a.store.TakenNames(ctx, candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range candidates {
+			if len(suggestions) >= count {
+				break
+			}
+			if !slices.Contains(taken, c) {
+				suggestions = append(suggestions, c)
+			}
+		}
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/policy"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestAvailableFormatError(t *testing.T) {
+	a := newTestAPI(newFakeStore())
+	a.policy = policy.Default()
+
+	router := bunrouter.New()
+	router.GET("/available/:name", a.availableHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/available/%3Cscript%3E.sarafu.eth", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Result map[string]any `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := slices.Sorted(maps.Keys(resp.Result))
+	want := []string{"available", "name", "suggestions", "taken", "violations"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("result keys = %v, want %v", keys, want)
+	}
+	if resp.Result["available"] != false || resp.Result["taken"] != false {
+		t.Errorf("available = %v, taken = %v, want both false", resp.Result["available"], resp.Result["taken"])
+	}
+	if resp.Result["name"] != "" {
+		t.Errorf("name = %q, want the raw parameter not echoed", resp.Result["name"])
+	}
+}
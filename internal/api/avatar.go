@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grassrootseconomics/ens-offchain-resolver/pkg/ens"
+	goens "github.com/grassrootseconomics/go-ens/v3"
+	"github.com/jackc/pgx/v5"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	defaultAvatarCacheTTL = time.Hour
+
+	avatarTextKey = "avatar"
+	// avatarCSP keeps scripts of SVG avatars from running on our origin.
+	avatarCSP = "default-src 'none'; style-src 'unsafe-inline'; sandbox"
+)
+
+// avatarHandler serves the image of the ENSIP-12 avatar record of a name, so that wallets and apps can use it as
+// an image URL for names of this resolver and for any ENS name alike.
+func (a *API) avatarHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	name, err := goens.Normalize(strings.ToLower(req.Param("name")))
+	if err != nil || name == "" {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid name",
+		})
+	}
+
+	record, owner, err := a.lookupAvatarRecord(req.Context(), name)
+	if err != nil {
+		if errors.Is(err, ens.ErrUnavailable) {
+			return a.upstreamUnavailable(w, err)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			a.logg.Error("lookup avatar record failed", "name", name, "error", err)
+		}
+
+		return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+			Ok:          false,
+			Description: "Name not found",
+		})
+	}
+	if record == "" {
+		return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+			Ok:          false,
+			Description: "Name has no avatar",
+		})
+	}
+
+	avatar, err := a.ensProvider.ResolveAvatar(req.Context(), record, owner)
+	if err != nil {
+		switch {
+		case errors.Is(err, ens.ErrUnavailable):
+			return a.upstreamUnavailable(w, err)
+		case errors.Is(err, ens.ErrUnsupportedAvatar), errors.Is(err, ens.ErrAvatarNotOwned):
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Avatar not found",
+			})
+		}
+
+		a.logg.Warn("fetch avatar failed", "name", name, "error", err)
+		return httputil.JSON(w, http.StatusBadGateway, ErrResponse{
+			Ok:          false,
+			Description: "Could not fetch avatar",
+		})
+	}
+
+	w.Header().Set("Content-Type", avatar.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(avatar.Data)))
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(a.avatarCacheTTL.Seconds())))
+	w.Header().Set("Content-Security-Policy", avatarCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(avatar.Data)
+
+	return err
+}
+
+// lookupAvatarRecord returns the avatar record of a name and the address it resolves to, NFT avatars have to be
+// held by it. Names the resolver does not manage are read through ENS.
+func (a *API) lookupAvatarRecord(ctx context.Context, name string) (string, common.Address, error) {
+	record, err := a.store.LookupNameRecord(ctx, name)
+	if err == nil {
+		texts, err := a.store.LookupTextRecords(ctx, record.Name)
+		if err != nil {
+			return "", common.Address{}, err
+		}
+
+		return texts[avatarTextKey], common.HexToAddress(record.Address), nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", common.Address{}, err
+	}
+
+	records, err := a.ensProvider.ResolveRecords(ctx, name, []uint64{ethCoinType}, []string{avatarTextKey})
+	if err != nil {
+		if errors.Is(err, ens.ErrUnavailable) {
+			return "", common.Address{}, err
+		}
+		return "", common.Address{}, pgx.ErrNoRows
+	}
+
+	return records.Texts[avatarTextKey], common.HexToAddress(records.Addresses[ethCoinType]), nil
+}
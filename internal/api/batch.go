@@ -0,0 +1,153 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const defaultBatchLimit = 100
+
+// batchResolveHandler resolves several names with a single store query. Unlike resolveHandler it does not
+// fall back to ENS for names the resolver does not know.
+func (a *API) batchResolveHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var batchReq BatchResolveRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &batchReq); err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	if len(batchReq.Names) > a.batchLimit {
+		return a.batchTooLarge(w)
+	}
+
+	if rejected, err := a.rejectByBatchRateLimit(w, req, len(batchReq.Names)); rejected {
+		return err
+	}
+
+	names := make([]string, len(batchReq.Names))
+	for i, name := range batchReq.Names {
+		names[i] = strings.ToLower(name)
+	}
+
+	addresses, err := a.store.BatchLookupNames(req.Context(), dedupe(names))
+	if err != nil {
+		a.logg.Error("batch lookup names failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	results := make([]BatchResult, len(names))
+	for i, name := range names {
+		address, found := addresses[name]
+		results[i] = BatchResult{
+			Name:    name,
+			Address: address,
+			Found:   found,
+		}
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Names resolved",
+		Result: map[string]any{
+			"results": results,
+		},
+	})
+}
+
+func (a *API) batchReverseResolveHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var batchReq BatchReverseResolveRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &batchReq); err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Address validation failed",
+		})
+	}
+
+	if len(batchReq.Addresses) > a.batchLimit {
+		return a.batchTooLarge(w)
+	}
+
+	if rejected, err := a.rejectByBatchRateLimit(w, req, len(batchReq.Addresses)); rejected {
+		return err
+	}
+
+	names, err := a.store.BatchReverseLookup(req.Context(), dedupe(batchReq.Addresses))
+	if err != nil {
+		a.logg.Error("batch reverse lookup failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	primaryNames := make([]string, 0, len(names))
+	for _, name := range names {
+		primaryNames = append(primaryNames, name)
+	}
+	resolved, err := a.store.BatchLookupNames(req.Context(), dedupe(primaryNames))
+	if err != nil {
+		a.logg.Error("batch forward verification failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	results := make([]BatchResult, len(batchReq.Addresses))
+	for i, address := range batchReq.Addresses {
+		name, found := names[address]
+		results[i] = BatchResult{
+			Name:    name,
+			Address: address,
+			Found:   found,
+		}
+		if found {
+			verified := strings.EqualFold(resolved[name], address)
+			results[i].Verified = &verified
+		}
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Addresses reverse resolved",
+		Result: map[string]any{
+			"results": results,
+		},
+	})
+}
+
+func (a *API) batchTooLarge(w http.ResponseWriter) error {
+	return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+		Ok:          false,
+		Description: fmt.Sprintf("At most %d items per batch", a.batchLimit),
+	})
+}
+
+// rejectByBatchRateLimit charges a batch of n items to the batch bucket of the client IP and responds with 429
+// when it runs out.
+func (a *API) rejectByBatchRateLimit(w http.ResponseWriter, req bunrouter.Request, n int) (bool, error) {
+	if a.batchLimiter == nil || a.batchLimiter.allowN(a.clientIPKey(req), max(n, 1)) {
+		return false, nil
+	}
+
+	return true, a.batchLimiter.tooManyRequests(w, n)
+}
+
+// dedupe returns the distinct values of s, the order is not preserved.
+func dedupe(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return slices.Compact(s)
+}
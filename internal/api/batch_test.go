@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	batchAlice = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	batchBob   = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+)
+
+func batchRequest(t *testing.T, handler bunrouter.HandlerFunc, body string) (int, []BatchResult) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	if err := handler(rec, bunrouter.NewRequest(req)); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp struct {
+		Result struct {
+			Results []BatchResult `json:"results"`
+		} `json:"result"`
+	}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, resp.Result.Results
+}
+
+func TestBatchResolveKeepsOrder(t *testing.T) {
+	f := newFakeStore()
+	f.names["alice.sarafu.eth"] = &store.NameRecord{Name: "alice.sarafu.eth", Address: batchAlice}
+	f.names["bob.sarafu.eth"] = &store.NameRecord{Name: "bob.sarafu.eth", Address: batchBob}
+	a := newTestAPI(f)
+	a.batchLimit = defaultBatchLimit
+
+	code, results := batchRequest(t, a.batchResolveHandler, `{"names":["bob.sarafu.eth","unknown.sarafu.eth","Alice.sarafu.eth","bob.sarafu.eth"]}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+
+	want := []BatchResult{
+		{Name: "bob.sarafu.eth", Address: batchBob, Found: true},
+		{Name: "unknown.sarafu.eth"},
+		{Name: "alice.sarafu.eth", Address: batchAlice, Found: true},
+		{Name: "bob.sarafu.eth", Address: batchBob, Found: true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+}
+
+func TestBatchReverseResolveVerified(t *testing.T) {
+	f := newFakeStore()
+	f.names["alice.sarafu.eth"] = &store.NameRecord{Name: "alice.sarafu.eth", Address: batchAlice, Primary: true}
+	f.names["moved.sarafu.eth"] = &store.NameRecord{Name: "moved.sarafu.eth", Address: batchAlice}
+	a := newTestAPI(staleReverseStore{f, batchBob, "moved.sarafu.eth"})
+	a.batchLimit = defaultBatchLimit
+
+	code, results := batchRequest(t, a.batchReverseResolveHandler, `{"addresses":["`+batchBob+`","`+batchAlice+`"]}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	if r := results[0]; r.Address != batchBob || r.Name != "moved.sarafu.eth" || r.Verified == nil || *r.Verified {
+		t.Errorf("bob = %+v, want found and not verified", r)
+	}
+	if r := results[1]; r.Address != batchAlice || r.Name != "alice.sarafu.eth" || r.Verified == nil || !*r.Verified {
+		t.Errorf("alice = %+v, want found and verified", r)
+	}
+}
+
+func TestBatchRateLimitChargesPerItem(t *testing.T) {
+	a := newTestAPI(newFakeStore())
+	a.batchLimit = 3
+	a.batchLimiter = newRateLimiter("test", RateLimitOpts{RPS: 0.001, Burst: 5})
+
+	body := `{"names":["a.sarafu.eth","b.sarafu.eth","c.sarafu.eth"]}`
+	if code, _ := batchRequest(t, a.batchResolveHandler, body); code != http.StatusOK {
+		t.Fatalf("first batch status = %d, want %d", code, http.StatusOK)
+	}
+	if code, _ := batchRequest(t, a.batchResolveHandler, body); code != http.StatusTooManyRequests {
+		t.Errorf("second batch status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code, _ := batchRequest(t, a.batchResolveHandler, `{"names":["a.sarafu.eth","b.sarafu.eth"]}`); code != http.StatusOK {
+		t.Errorf("batch within the remaining tokens status = %d, want %d", code, http.StatusOK)
+	}
+}
@@ -0,0 +1,381 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	defaultBulkLimit = 1000
+	// Bulk requests are far larger than the 10KB httputil.BindJSON allows.
+	maxBulkBodySize = 4 << 20
+
+	bulkModePerRow = "per_row"
+	bulkModeAtomic = "atomic"
+
+	bulkStatusRegistered = "registered"
+	// bulkStatusRenamed marks entries registered under an autoChoose alternative of their hint.
+	bulkStatusRenamed = "renamed"
+	// bulkStatusExisting marks entries whose address already holds a name of the kind, so imports can be rerun.
+	bulkStatusExisting = "existing"
+	bulkStatusFailed   = "failed"
+	// bulkStatusSkipped marks valid entries of an atomic request that was not committed.
+	bulkStatusSkipped = "skipped"
+)
+
+// bulkEntry is a validated bulk registration that still has to be registered.
+type bulkEntry struct {
+	result *BulkResult
+	label  string
+	kind   string
+	texts  map[string]string
+}
+
+// bulkRegisterHandler registers community member lists with the same validation, normalization and policy as
+// registerHandler. Pass ?format=csv to receive the report as a CSV download.
+func (a *API) bulkRegisterHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var bulkReq BulkRegisterRequest
+
+	req.Body = http.MaxBytesReader(w, req.Body, maxBulkBodySize)
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&bulkReq); err != nil {
+		a.logg.Error("bulk register decode failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	if err := a.validator.Validate(bulkReq); err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	if len(bulkReq.Entries) > a.bulkLimit {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "At most " + strconv.Itoa(a.bulkLimit) + " entries per request",
+		})
+	}
+
+	report := BulkReport{
+		Mode:    bulkReq.Mode,
+		Results: make([]BulkResult, len(bulkReq.Entries)),
+	}
+	if report.Mode == "" {
+		report.Mode = bulkModePerRow
+	}
+
+	var pending []bulkEntry
+	// An address gets one name of a kind, later entries for it would otherwise all pass the existing check.
+	firstEntry := make(map[string]int)
+	for i, entry := range bulkReq.Entries {
+		report.Results[i] = BulkResult{
+			Index:   i,
+			Address: entry.Address,
+			Hint:    entry.Hint,
+		}
+		prepared, ok := a.prepareBulkEntry(req.Context(), entry, &report.Results[i])
+		if !ok {
+			continue
+		}
+
+		key := entry.Address + ":" + prepared.kind
+		if first, seen := firstEntry[key]; seen {
+			report.Results[i].Status = bulkStatusFailed
+			report.Results[i].Error = "Duplicate address of entry " + strconv.Itoa(first)
+			continue
+		}
+		firstEntry[key] = i
+		pending = append(pending, prepared)
+	}
+
+	var err error
+	if report.Mode == bulkModeAtomic {
+		err = a.registerBulkAtomic(req.Context(), pending, &report)
+	} else {
+		for _, entry := range pending {
+			a.registerBulkEntry(req.Context(), entry)
+		}
+		report.Committed = true
+	}
+	if err != nil {
+		if isUniqueViolation(err) || errors.Is(err, store.ErrNameReserved) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Concurrent registration of a chosen name, retry",
+			})
+		}
+
+		a.logg.Error("bulk register failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	for _, result := range report.Results {
+		switch result.Status {
+		case bulkStatusRegistered:
+			report.Registered++
+		case bulkStatusRenamed:
+			report.Renamed++
+		case bulkStatusExisting:
+			report.Existing++
+		case bulkStatusFailed:
+			report.Failed++
+		}
+	}
+
+	if req.URL.Query().Get("format") == "csv" {
+		return writeBulkReportCSV(w, report)
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Bulk registration processed",
+		Result: map[string]any{
+			"report": report,
+		},
+	})
+}
+
+// prepareBulkEntry validates an entry, ok is false when it failed or its address already holds a name.
+func (a *API) prepareBulkEntry(ctx context.Context, entry RegisterRequest, result *BulkResult) (bulkEntry, bool) {
+	fail := func(description string) (bulkEntry, bool) {
+		result.Status = bulkStatusFailed
+		result.Error = description
+		return bulkEntry{}, false
+	}
+
+	if entry.ReservationToken != "" {
+		return fail("Reservation tokens are not supported in bulk registration")
+	}
+
+	if err := a.validator.Validate(entry); err != nil {
+		return fail("Validation failed")
+	}
+
+	label, kind, err := normalizeRegistration(entry)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	if violations := a.policy.Check(label); len(violations) > 0 {
+		rules := make([]string, len(violations))
+		for i, v := range violations {
+			rules[i] = v.Rule
+		}
+		return fail("Name is not allowed: " + strings.Join(rules, ", "))
+	}
+
+	existing, err := a.store.ListAddressNames(ctx, entry.Address, kind)
+	if err != nil {
+		a.logg.Error("list address names failed", "error", err)
+		return fail("Internal server error")
+	}
+	if len(existing) > 0 {
+		result.Name = existing[0].Name
+		result.Status = bulkStatusExisting
+		return bulkEntry{}, false
+	}
+
+	return bulkEntry{
+		result: result,
+		label:  label,
+		kind:   kind,
+		texts:  entry.Texts,
+	}, true
+}
+
+// registerBulkEntry registers a single entry the way registerHandler does, falling back to autoChoose for taken
+// user names.
+func (a *API) registerBulkEntry(ctx context.Context, entry bulkEntry) {
+	name := fullName(entry.label, entry.kind)
+
+	err := a.store.RegisterName(ctx, store.NewName{
+		Name:    name,
+		Address: entry.result.Address,
+		Kind:    entry.kind,
+		Texts:   entry.texts,
+		Tenant:  tenantFromContext(ctx),
+	})
+	if err == nil {
+		entry.result.Name = name
+		entry.result.Status = bulkStatusRegistered
+		return
+	}
+
+	entry.result.Status = bulkStatusFailed
+	if !errors.Is(err, store.ErrNameReserved) && !isUniqueViolation(err) {
+		a.logg.Error("bulk register entry failed", "name", name, "error", err)
+		entry.result.Error = "Internal server error"
+		return
+	}
+
+	if entry.kind != kindUser {
+		entry.result.Error = "Name already taken"
+		return
+	}
+
+	name, err = a.registerAutoChosen(ctx, entry.label, store.NewName{
+		Address: entry.result.Address,
+		Texts:   entry.texts,
+		Tenant:  tenantFromContext(ctx),
+	})
+	if err != nil {
+		if errors.Is(err, errAutoChooseExhausted) {
+			entry.result.Error = "Autochoose error, try a different hint"
+			return
+		}
+
+		a.logg.Error("bulk autochoose failed", "error", err)
+		entry.result.Error = "Internal server error"
+		return
+	}
+
+	entry.result.Name = name
+	entry.result.Status = bulkStatusRenamed
+}
+
+// registerBulkAtomic picks a free name for every entry up front and registers all of them in one transaction.
+// Nothing is registered when an entry fails, the report then lists the failures and skips the rest.
+func (a *API) registerBulkAtomic(ctx context.Context, pending []bulkEntry, report *BulkReport) error {
+	abort := func() error {
+		for _, entry := range pending {
+			if entry.result.Status != bulkStatusFailed {
+				entry.result.Name = ""
+				entry.result.Status = bulkStatusSkipped
+			}
+		}
+		return nil
+	}
+
+	for _, result := range report.Results {
+		if result.Status == bulkStatusFailed {
+			return abort()
+		}
+	}
+
+	hints := make([]string, len(pending))
+	for i, entry := range pending {
+		hints[i] = fullName(entry.label, entry.kind)
+	}
+
+	taken, err := a.store.TakenNames(ctx, hints)
+	if err != nil {
+		return err
+	}
+	claimed := make(map[string]bool, len(pending)+len(taken))
+	for _, name := range taken {
+		claimed[name] = true
+	}
+
+	names := make([]store.NewName, 0, len(pending))
+	failed := false
+	for i, entry := range pending {
+		name, status := hints[i], bulkStatusRegistered
+		if claimed[name] {
+			if entry.kind != kindUser {
+				entry.result.Status = bulkStatusFailed
+				entry.result.Error = "Name already taken"
+				failed = true
+				continue
+			}
+
+			name, err = a.chooseAvailable(ctx, entry.label, claimed)
+			if err != nil {
+				if !errors.Is(err, errAutoChooseExhausted) {
+					return err
+				}
+				entry.result.Status = bulkStatusFailed
+				entry.result.Error = "Autochoose error, try a different hint"
+				failed = true
+				continue
+			}
+			status = bulkStatusRenamed
+		}
+
+		claimed[name] = true
+		entry.result.Name = name
+		entry.result.Status = status
+		names = append(names, store.NewName{
+			Name:    name,
+			Address: entry.result.Address,
+			Kind:    entry.kind,
+			Texts:   entry.texts,
+			Tenant:  tenantFromContext(ctx),
+		})
+	}
+	if failed {
+		return abort()
+	}
+
+	if err := a.store.RegisterNames(ctx, names); err != nil {
+		return err
+	}
+	report.Committed = true
+
+	return nil
+}
+
+// chooseAvailable returns the first autoChoose candidate that is neither registered nor claimed by an earlier
+// entry of the same request.
+func (a *API) chooseAvailable(ctx context.Context, subdomain string, claimed map[string]bool) (string, error) {
+	for round := range autoChooseRounds {
+		candidates, ok := a.autoChooseCandidates(subdomain, round)
+		if !ok {
+			break
+		}
+
+		taken, err := a.store.TakenNames(ctx, candidates)
+		if err != nil {
+			return "", err
+		}
+		for _, name := range taken {
+			claimed[name] = true
+		}
+
+		for _, name := range candidates {
+			if !claimed[name] {
+				return name, nil
+			}
+		}
+	}
+
+	return "", errAutoChooseExhausted
+}
+
+func writeBulkReportCSV(w http.ResponseWriter, report BulkReport) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="bulk-register-report.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"index", "address", "hint", "name", "status", "error"})
+	for _, result := range report.Results {
+		cw.Write([]string{
+			strconv.Itoa(result.Index),
+			result.Address,
+			result.Hint,
+			result.Name,
+			result.Status,
+			result.Error,
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
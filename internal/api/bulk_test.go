@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/policy"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestBulkRegisterDuplicateAddress(t *testing.T) {
+	const address = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	for _, mode := range []string{bulkModePerRow, bulkModeAtomic} {
+		t.Run(mode, func(t *testing.T) {
+			f := newFakeStore()
+			a := newTestAPI(f)
+			a.bulkLimit = defaultBulkLimit
+			a.policy = policy.Default()
+
+			body, err := json.Marshal(BulkRegisterRequest{
+				Mode: mode,
+				Entries: []RegisterRequest{
+					{Address: address, Hint: "alice.sarafu.eth"},
+					{Address: address, Hint: "bob.sarafu.eth"},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+			rec := httptest.NewRecorder()
+			if err := a.bulkRegisterHandler(rec, bunrouter.NewRequest(req)); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+
+			var resp struct {
+				Result struct {
+					Report BulkReport `json:"report"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := resp.Result.Report.Results[0].Status; got == bulkStatusFailed {
+				t.Fatalf("first entry failed: %s", resp.Result.Report.Results[0].Error)
+			}
+			if got := resp.Result.Report.Results[1].Status; got != bulkStatusFailed {
+				t.Errorf("duplicate entry status = %q, want %q", got, bulkStatusFailed)
+			}
+			names, _ := f.ListAddressNames(t.Context(), address, kindUser)
+			if len(names) > 1 {
+				t.Errorf("address holds %d names, want at most one", len(names))
+			}
+		})
+	}
+}
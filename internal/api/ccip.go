@@ -2,10 +2,12 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -24,6 +26,13 @@ type (
 		Sender string
 		Data   string
 	}
+
+	// resolverCall is a decoded resolver function call, key is only set for text.
+	resolverCall struct {
+		selector string
+		node     common.Hash
+		key      string
+	}
 )
 
 const (
@@ -33,6 +42,19 @@ const (
 
 	AddrSignature      string = "0x3b3b57de"
 	MulticoinSignature string = "0xf1cb7e06"
+	TextSignature      string = "0x59d1d43c"
+	// ContenthashSignature is answered from the contenthash text record.
+	ContenthashSignature string = "0xbc1c58d1"
+	// NameSignature is answered for <address>.addr.reverse names with the verified primary name of the address.
+	NameSignature string = "0x691f3431"
+
+	// expiryTextKey is answered with the expiry of the name as a unix timestamp instead of a stored record,
+	// it is empty for names that never expire.
+	expiryTextKey = "expires"
+	// contenthashTextKey holds the EIP-1577 content hash of a name in its text form, e.g. ipfs://<cid>.
+	contenthashTextKey = "contenthash"
+	// reverseSuffix is the parent of the ENSIP-3 reverse names of Ethereum addresses.
+	reverseSuffix = ".addr.reverse"
 )
 
 var (
@@ -43,8 +65,11 @@ var (
 
 	// https://docs.ens.domains/resolvers/interfaces/#resolver-interface-standards/
 	signatures = map[string]*w3.Func{
-		AddrSignature:      w3.MustNewFunc("addr(bytes32)", "address"),
-		MulticoinSignature: w3.MustNewFunc("addr(bytes32,uint256)", "bytes"),
+		AddrSignature:        w3.MustNewFunc("addr(bytes32)", "address"),
+		MulticoinSignature:   w3.MustNewFunc("addr(bytes32,uint256)", "bytes"),
+		TextSignature:        w3.MustNewFunc("text(bytes32,string)", "string"),
+		ContenthashSignature: w3.MustNewFunc("contenthash(bytes32)", "bytes"),
+		NameSignature:        w3.MustNewFunc("name(bytes32)", "string"),
 	}
 )
 
@@ -71,7 +96,7 @@ func (a *API) ccipHandler(w http.ResponseWriter, req bunrouter.Request) error {
 	a.logg.Debug("decoded ENS name", "name", ensName)
 	a.logg.Debug("decoded inner data", "data", hexutil.Encode(innerData))
 
-	value, err := a.decodeInnerData(hexutil.Encode(innerData))
+	call, err := a.decodeInnerData(hexutil.Encode(innerData))
 	if err != nil {
 		if err == ErrUnsupportedFunction {
 			return httputil.JSON(w, http.StatusBadRequest, CCIPErrResponse{
@@ -83,7 +108,7 @@ func (a *API) ccipHandler(w http.ResponseWriter, req bunrouter.Request) error {
 			Message: "Bad data.",
 		})
 	}
-	a.logg.Debug("inner data return value", "value", value.Hex())
+	a.logg.Debug("inner data return value", "value", call.node.Hex())
 
 	encodedNameHash, err := goens.NameHash(ensName)
 	if err != nil {
@@ -92,13 +117,13 @@ func (a *API) ccipHandler(w http.ResponseWriter, req bunrouter.Request) error {
 		})
 	}
 
-	if !bytes.Equal(encodedNameHash[:], value.Bytes()) {
+	if !bytes.Equal(encodedNameHash[:], call.node.Bytes()) {
 		return httputil.JSON(w, http.StatusBadRequest, CCIPErrResponse{
 			Message: "Could not validate name.",
 		})
 	}
 
-	address, err := a.store.LookupName(req.Context(), ensName)
+	resultBytes, err := a.resolveCall(req.Context(), ensName, call, hexutil.Encode(innerData))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return httputil.JSON(w, http.StatusBadRequest, CCIPErrResponse{
@@ -111,8 +136,6 @@ func (a *API) ccipHandler(w http.ResponseWriter, req bunrouter.Request) error {
 		})
 	}
 
-	resultBytes := a.encodeAddress(hexutil.Encode(innerData), w3.A(address))
-
 	payload, err := a.ensProvider.SignPayload(
 		common.HexToAddress(r.Sender),
 		w3.B(r.Data),
@@ -130,37 +153,140 @@ func (a *API) ccipHandler(w http.ResponseWriter, req bunrouter.Request) error {
 	})
 }
 
-func (a *API) decodeInnerData(nestedDataHex string) (*common.Hash, error) {
+func (a *API) decodeInnerData(nestedDataHex string) (*resolverCall, error) {
 	if len(nestedDataHex) < 10 {
 		return nil, fmt.Errorf("invalid nested data hex")
 	}
 
-	switch nestedDataHex[:10] {
+	call := resolverCall{selector: nestedDataHex[:10]}
+	switch call.selector {
 	case AddrSignature:
-		var result common.Hash
-		if err := signatures[AddrSignature].DecodeArgs(w3.B(nestedDataHex), &result); err != nil {
+		if err := signatures[AddrSignature].DecodeArgs(w3.B(nestedDataHex), &call.node); err != nil {
 			return nil, err
 		}
-		return &result, nil
+		return &call, nil
 	case MulticoinSignature:
-		var (
-			result   common.Hash
-			coinType *big.Int
-		)
-		if err := signatures[MulticoinSignature].DecodeArgs(w3.B(nestedDataHex), &result, &coinType); err != nil {
+		var coinType *big.Int
+		if err := signatures[MulticoinSignature].DecodeArgs(w3.B(nestedDataHex), &call.node, &coinType); err != nil {
 			return nil, err
 		}
 		a.logg.Debug("decoded coin type", "coinType", coinType)
 		if coinType.Cmp(big.NewInt(CELO_COIN)) == 0 {
-			return &result, nil
+			return &call, nil
 		} else {
 			return nil, ErrUnsupportedFunction
 		}
+	case TextSignature:
+		if err := signatures[TextSignature].DecodeArgs(w3.B(nestedDataHex), &call.node, &call.key); err != nil {
+			return nil, err
+		}
+		return &call, nil
+	case ContenthashSignature:
+		if err := signatures[ContenthashSignature].DecodeArgs(w3.B(nestedDataHex), &call.node); err != nil {
+			return nil, err
+		}
+		return &call, nil
+	case NameSignature:
+		if err := signatures[NameSignature].DecodeArgs(w3.B(nestedDataHex), &call.node); err != nil {
+			return nil, err
+		}
+		return &call, nil
 	}
 
 	return nil, ErrUnsupportedFunction
 }
 
+// resolveCall looks up the answer to a decoded resolver call and returns it ABI encoded. Names past their
+// expiry grace period are not found.
+func (a *API) resolveCall(ctx context.Context, name string, call *resolverCall, nestedDataHex string) ([]byte, error) {
+	if call.selector == TextSignature {
+		value, err := a.resolveText(ctx, name, call.key)
+		if err != nil {
+			return nil, err
+		}
+
+		return abi.Arguments{{Type: abi.Type{T: abi.StringTy}}}.Pack(value)
+	}
+
+	if call.selector == ContenthashSignature {
+		value, err := a.resolveText(ctx, name, contenthashTextKey)
+		if err != nil {
+			return nil, err
+		}
+
+		contenthash := []byte{}
+		if value != "" {
+			if contenthash, err = goens.StringToContenthash(value); err != nil {
+				return nil, err
+			}
+		}
+
+		return abi.Arguments{{Type: abi.Type{T: abi.BytesTy}}}.Pack(contenthash)
+	}
+
+	if call.selector == NameSignature {
+		value, err := a.resolveReverseName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		return abi.Arguments{{Type: abi.Type{T: abi.StringTy}}}.Pack(value)
+	}
+
+	address, err := a.store.LookupName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.encodeAddress(nestedDataHex, w3.A(address)), nil
+}
+
+func (a *API) resolveText(ctx context.Context, name string, key string) (string, error) {
+	record, err := a.store.LookupNameRecord(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	if key == expiryTextKey {
+		if record.ExpiresAt == nil {
+			return "", nil
+		}
+		return strconv.FormatInt(record.ExpiresAt.Unix(), 10), nil
+	}
+
+	texts, err := a.store.LookupTextRecords(ctx, record.Name)
+	if err != nil {
+		return "", err
+	}
+
+	return texts[key], nil
+}
+
+// resolveReverseName returns the primary name of the address of a reverse name. Unlike the REST route, primary
+// names that do not resolve back to the address are not found, resolvers must not serve them.
+func (a *API) resolveReverseName(ctx context.Context, name string) (string, error) {
+	label, ok := strings.CutSuffix(name, reverseSuffix)
+	if !ok || !common.IsHexAddress(label) {
+		return "", pgx.ErrNoRows
+	}
+	address := common.HexToAddress(label).Hex()
+
+	primaryName, err := a.store.ReverseLookup(ctx, address)
+	if err != nil {
+		return "", err
+	}
+
+	verified, err := a.verifyReverse(ctx, address, primaryName)
+	if err != nil {
+		return "", err
+	}
+	if !verified {
+		return "", pgx.ErrNoRows
+	}
+
+	return primaryName, nil
+}
+
 // TODO: Massive refactor needed here
 func (a *API) encodeAddress(nestedDataHex string, addr common.Address) []byte {
 	if len(nestedDataHex) < 10 {
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	goens "github.com/grassrootseconomics/go-ens/v3"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestResolveReverseName(t *testing.T) {
+	const (
+		alice = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+		bob   = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+		carol = "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB"
+	)
+
+	f := newFakeStore()
+	f.names["alice.sarafu.eth"] = &store.NameRecord{Name: "alice.sarafu.eth", Address: alice, Primary: true}
+	f.names["moved.sarafu.eth"] = &store.NameRecord{Name: "moved.sarafu.eth", Address: alice}
+	a := newTestAPI(f)
+
+	name := func(t *testing.T, reverseName string) (string, error) {
+		t.Helper()
+
+		node, err := goens.NameHash(reverseName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := signatures[NameSignature].EncodeArgs(node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		call, err := a.decodeInnerData(hexutil.Encode(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := a.resolveCall(t.Context(), reverseName, call, hexutil.Encode(data))
+		if err != nil {
+			return "", err
+		}
+		var value string
+		if err := signatures[NameSignature].DecodeReturns(result, &value); err != nil {
+			t.Fatal(err)
+		}
+		return value, nil
+	}
+
+	reverseName := func(address string) string {
+		return strings.ToLower(address[2:]) + reverseSuffix
+	}
+
+	if got, err := name(t, reverseName(alice)); err != nil || got != "alice.sarafu.eth" {
+		t.Errorf("name(alice) = %q, %v, want alice.sarafu.eth", got, err)
+	}
+
+	a.store = staleReverseStore{f, bob, "moved.sarafu.eth"}
+	if got, err := name(t, reverseName(bob)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("name(bob) = %q, %v, want a primary name resolving elsewhere not found", got, err)
+	}
+
+	a.store = staleReverseStore{f, carol, "gone.sarafu.eth"}
+	if got, err := name(t, reverseName(carol)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("name(carol) = %q, %v, want a primary name that does not resolve not found", got, err)
+	}
+
+	if got, err := name(t, "alice.sarafu.eth"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("name(alice.sarafu.eth) = %q, %v, want only reverse names answered", got, err)
+	}
+}
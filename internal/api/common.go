@@ -32,7 +32,7 @@ func newCorsMiddleware(allowedOrigins []string) bunrouter.MiddlewareFunc {
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   allowedOrigins,
 		AllowCredentials: true,
-		AllowedHeaders:   []string{"Content-Type", "Authorization", "Accept", "Origin"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "Accept", "Origin", apiKeyHeader, idempotencyKeyHeader},
 		MaxAge:           86400,
 	})
 
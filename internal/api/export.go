@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/snapshot"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+// exportFlushEvery is how many names are buffered before the export is flushed to the client.
+const exportFlushEvery = 500
+
+// countingWriter tracks whether anything reached the client, after which the status can no longer change.
+type countingWriter struct {
+	w http.ResponseWriter
+	n int
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += n
+	return n, err
+}
+
+// exportHandler streams every active name with its text records from a single snapshot, as JSONL by default
+// or as CSV with ?format=csv. Once anything has been sent, errors can only be signalled by cutting the stream
+// short.
+func (a *API) exportHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = snapshot.FormatJSONL
+	}
+	if format != snapshot.FormatJSONL && format != snapshot.FormatCSV {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Format must be jsonl or csv",
+		})
+	}
+
+	w.Header().Set("Content-Type", snapshot.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="names-`+time.Now().UTC().Format("20060102T150405Z")+`.`+format+`"`)
+
+	out := &countingWriter{w: w}
+	sw, err := snapshot.NewWriter(out, format)
+	if err != nil {
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	exported := 0
+	err = a.store.ExportNames(req.Context(), func(name store.ExportedName) error {
+		if err := sw.Write(name); err != nil {
+			return err
+		}
+
+		exported++
+		if exported%exportFlushEvery == 0 {
+			if err := sw.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		a.logg.Error("export failed", "exported", exported, "error", err)
+		if out.n > 0 {
+			return nil
+		}
+
+		// Nothing has been sent yet, the buffered names are dropped.
+		w.Header().Del("Content-Disposition")
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	if err := sw.Flush(); err != nil {
+		a.logg.Error("export flush failed", "error", err)
+	}
+	a.logg.Info("names exported", "format", format, "count", exported)
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	defaultIdempotencyWindow = 24 * time.Hour
+	// idempotencyLease is how long a claim may stay in flight before a retry takes it over, it outlasts any
+	// handler so that only claims left behind by a crash or a lost release are taken over.
+	idempotencyLease        = time.Minute
+	maxIdempotencyKeyLength = 255
+	// Matches the body limit enforced by httputil.BindJSON.
+	maxIdempotentBodySize = 10 << 10
+)
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// idempotencyMiddleware replays the first response for retries carrying the same Idempotency-Key. Keys are
+// scoped to the authenticated subject and must be used after authMiddleware.
+func (a *API) idempotencyMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		key := req.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			return next(w, req)
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+				Ok:          false,
+				Description: "Idempotency key is too long",
+			})
+		}
+
+		p := principalFromContext(req.Context())
+		if p == nil {
+			return next(w, req)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+				Ok:          false,
+				Description: "Could not read request body",
+			})
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := hashRequest(req, body)
+		record, err := a.store.ClaimIdempotencyKey(req.Context(), p.Subject, key, requestHash, a.idempotencyWindow, idempotencyLease)
+		if err != nil {
+			a.logg.Error("claim idempotency key failed", "error", err)
+			return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+				Ok:          false,
+				Description: "Internal server error",
+			})
+		}
+
+		if record != nil {
+			if record.RequestHash != requestHash {
+				return httputil.JSON(w, http.StatusUnprocessableEntity, ErrResponse{
+					Ok:          false,
+					Description: "Idempotency key was already used for a different request",
+				})
+			}
+
+			if record.StatusCode == 0 {
+				return httputil.JSON(w, http.StatusConflict, ErrResponse{
+					Ok:          false,
+					Description: "A request with this idempotency key is still being processed",
+				})
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.StatusCode)
+			_, err := w.Write(record.Response)
+			return err
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		handlerErr := next(rec, req)
+
+		// Persist outside of the request context so that a client disconnect does not leave the key stuck.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), 5*time.Second)
+		defer cancel()
+
+		// Conflicts changed nothing and ask the client to retry, like server errors they are not replayed.
+		if handlerErr != nil || rec.statusCode == 0 || rec.statusCode == http.StatusConflict ||
+			rec.statusCode >= http.StatusInternalServerError {
+			if err := a.store.ReleaseIdempotencyKey(ctx, p.Subject, key); err != nil {
+				a.logg.Error("release idempotency key failed", "error", err)
+			}
+			return handlerErr
+		}
+
+		if err := a.store.CompleteIdempotencyKey(ctx, p.Subject, key, rec.statusCode, rec.body.Bytes()); err != nil {
+			a.logg.Error("complete idempotency key failed", "error", err)
+		}
+
+		return nil
+	}
+}
+
+// PurgeIdempotencyKeys deletes keys older than the idempotency window, it is run by the sweeper.
+func (a *API) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	return a.store.PurgeIdempotencyKeys(ctx, a.idempotencyWindow)
+}
+
+func hashRequest(req bunrouter.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
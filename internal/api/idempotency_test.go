@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	setup := func(status int) (*fakeStore, bunrouter.HandlerFunc, *int) {
+		f := newFakeStore()
+		a := newTestAPI(f)
+		a.idempotencyWindow = defaultIdempotencyWindow
+
+		calls := 0
+		handler := a.idempotencyMiddleware(func(w http.ResponseWriter, req bunrouter.Request) error {
+			calls++
+			return httputil.JSON(w, status, OKResponse{Ok: status == http.StatusOK, Description: "handled"})
+		})
+		return f, handler, &calls
+	}
+
+	call := func(t *testing.T, handler bunrouter.HandlerFunc, key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/internal/register", strings.NewReader(body))
+		req.Header.Set(idempotencyKeyHeader, key)
+		req = req.WithContext(context.WithValue(req.Context(), principalKey{}, &principal{Subject: "test"}))
+		rec := httptest.NewRecorder()
+		if err := handler(rec, bunrouter.NewRequest(req)); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		_, handler, calls := setup(http.StatusOK)
+
+		first := call(t, handler, "k1", `{"hint":"mama"}`)
+		second := call(t, handler, "k1", `{"hint":"mama"}`)
+		if *calls != 1 {
+			t.Errorf("handler ran %d times, want once", *calls)
+		}
+		if second.Code != first.Code || second.Body.String() != first.Body.String() {
+			t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+		}
+		if second.Header().Get("Idempotent-Replayed") != "true" {
+			t.Error("replay is not marked")
+		}
+	})
+
+	t.Run("different request", func(t *testing.T) {
+		_, handler, _ := setup(http.StatusOK)
+
+		call(t, handler, "k1", `{"hint":"mama"}`)
+		if rec := call(t, handler, "k1", `{"hint":"baba"}`); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+		}
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		f, handler, calls := setup(http.StatusOK)
+		f.idempotent["test/k1"] = &fakeIdempotencyKey{createdAt: time.Now()}
+		f.idempotent["test/k1"].RequestHash = hashRequest(bunrouter.NewRequest(
+			httptest.NewRequest(http.MethodPost, "/api/v1/internal/register", nil),
+		), []byte(`{"hint":"mama"}`))
+
+		if rec := call(t, handler, "k1", `{"hint":"mama"}`); rec.Code != http.StatusConflict {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+		}
+
+		// A claim older than the lease was left behind and is taken over.
+		f.idempotent["test/k1"].createdAt = time.Now().Add(-2 * idempotencyLease)
+		if rec := call(t, handler, "k1", `{"hint":"mama"}`); rec.Code != http.StatusOK || *calls != 1 {
+			t.Errorf("status = %d after %d calls, want the abandoned claim taken over", rec.Code, *calls)
+		}
+	})
+
+	for _, status := range []int{http.StatusConflict, http.StatusServiceUnavailable} {
+		t.Run("released "+http.StatusText(status), func(t *testing.T) {
+			f, handler, calls := setup(status)
+
+			call(t, handler, "k1", `{"hint":"mama"}`)
+			call(t, handler, "k1", `{"hint":"mama"}`)
+			if *calls != 2 {
+				t.Errorf("handler ran %d times, want the retry to run again", *calls)
+			}
+			if _, ok := f.idempotent["test/k1"]; ok {
+				t.Error("key was kept")
+			}
+		})
+	}
+}
@@ -1,10 +1,16 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/grassrootseconomics/ens-offchain-resolver/pkg/ens"
+	goens "github.com/grassrootseconomics/go-ens/v3"
 	"github.com/jackc/pgx/v5"
 	"github.com/kamikazechaser/common/httputil"
 	"github.com/uptrace/bunrouter"
@@ -23,11 +29,15 @@ func (a *API) resolveHandler(w http.ResponseWriter, req bunrouter.Request) error
 		})
 	}
 
-	address, err := a.store.LookupName(req.Context(), name)
+	record, err := a.store.LookupNameRecord(req.Context(), name)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			resolvedAddress, err := a.ensProvider.ResolveName(name)
+			resolvedAddress, err := a.ensProvider.ResolveName(req.Context(), name)
 			if err != nil {
+				if errors.Is(err, ens.ErrUnavailable) {
+					return a.upstreamUnavailable(w, err)
+				}
+
 				return httputil.JSON(w, http.StatusNotFound, ErrResponse{
 					Ok:          false,
 					Description: "Name not found",
@@ -53,11 +63,156 @@ func (a *API) resolveHandler(w http.ResponseWriter, req bunrouter.Request) error
 		Ok:          true,
 		Description: "Address resolved",
 		Result: map[string]any{
-			"address": address,
+			"name":      record.Name,
+			"address":   record.Address,
+			"owner":     record.Owner,
+			"expiresAt": record.ExpiresAt,
+			"expired":   record.Expired,
 		},
 	})
 }
 
+const (
+	ethCoinType = 60
+
+	sourceDB    = "db"
+	sourceChain = "chain"
+)
+
+var (
+	// profileCoinTypes and profileTextKeys are read from on-chain resolvers, whose records cannot be listed.
+	profileCoinTypes = []uint64{ethCoinType, CELO_COIN}
+	profileTextKeys  = []string{"avatar", "description", "url", "email", "com.twitter", "com.github", "org.telegram"}
+)
+
+// nameDetailsHandler returns everything a profile page needs in one call. Names the resolver does not manage
+// are read from their on-chain resolver, which has no owner or timestamps.
+func (a *API) nameDetailsHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	name, err := goens.Normalize(strings.ToLower(req.Param("name")))
+	if err != nil || name == "" {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid name",
+		})
+	}
+
+	record, err := a.store.LookupNameRecord(req.Context(), name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return a.chainNameDetails(req.Context(), w, name)
+		}
+
+		a.logg.Error("lookup name record failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	texts, err := a.store.LookupTextRecords(req.Context(), record.Name)
+	if err != nil {
+		a.logg.Error("lookup text records failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+	contenthash := texts[contenthashTextKey]
+	delete(texts, contenthashTextKey)
+
+	// Old names in their rename grace period are answered with the details of the current name.
+	details, err := nameDetails(record.Name)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid name",
+		})
+	}
+	details["addresses"] = map[string]string{
+		strconv.Itoa(ethCoinType): record.Address,
+		strconv.Itoa(CELO_COIN):   record.Address,
+	}
+	details["texts"] = texts
+	details["contenthash"] = contenthash
+	details["owner"] = record.Owner
+	details["kind"] = record.Kind
+	details["primary"] = record.Primary
+	details["expiresAt"] = record.ExpiresAt
+	details["expired"] = record.Expired
+	details["createdAt"] = record.CreatedAt
+	details["updatedAt"] = record.UpdatedAt
+	details["source"] = sourceDB
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name details",
+		Result:      details,
+	})
+}
+
+func (a *API) chainNameDetails(ctx context.Context, w http.ResponseWriter, name string) error {
+	records, err := a.ensProvider.ResolveRecords(ctx, name, profileCoinTypes, profileTextKeys)
+	if err != nil {
+		if errors.Is(err, ens.ErrUnavailable) {
+			return a.upstreamUnavailable(w, err)
+		}
+
+		return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+			Ok:          false,
+			Description: "Name not found",
+		})
+	}
+
+	contenthash := ""
+	if len(records.Contenthash) > 0 {
+		if contenthash, err = goens.ContenthashToString(records.Contenthash); err != nil {
+			contenthash = hexutil.Encode(records.Contenthash)
+		}
+	}
+
+	details, err := nameDetails(name)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid name",
+		})
+	}
+	addresses := make(map[string]string, len(records.Addresses))
+	for coinType, address := range records.Addresses {
+		addresses[strconv.FormatUint(coinType, 10)] = address
+	}
+	details["addresses"] = addresses
+	details["texts"] = records.Texts
+	details["contenthash"] = contenthash
+	details["source"] = sourceChain
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name details",
+		Result:      details,
+	})
+}
+
+// nameDetails returns the hashes identifying a normalized name.
+func nameDetails(name string) (map[string]any, error) {
+	nameHash, err := goens.NameHash(name)
+	if err != nil {
+		return nil, err
+	}
+
+	label, _, _ := strings.Cut(name, ".")
+	labelHash, err := goens.LabelHash(label)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"name":      name,
+		"namehash":  hexutil.Encode(nameHash[:]),
+		"labelhash": hexutil.Encode(labelHash[:]),
+	}, nil
+}
+
 func (a *API) reverseResolveHandler(w http.ResponseWriter, req bunrouter.Request) error {
 	r := PublicAddressParam{
 		Address: req.Param("address"),
@@ -73,9 +228,26 @@ func (a *API) reverseResolveHandler(w http.ResponseWriter, req bunrouter.Request
 	name, err := a.store.ReverseLookup(req.Context(), r.Address)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
-				Ok:          false,
-				Description: "Address not found",
+			name, err := a.ensProvider.ReverseResolve(req.Context(), common.HexToAddress(r.Address))
+			if err != nil {
+				if errors.Is(err, ens.ErrUnavailable) {
+					return a.upstreamUnavailable(w, err)
+				}
+
+				return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+					Ok:          false,
+					Description: "Address not found",
+				})
+			}
+
+			return httputil.JSON(w, http.StatusOK, OKResponse{
+				Ok:          true,
+				Description: "Name reverse resolved",
+				Result: map[string]any{
+					"name":     name,
+					"source":   sourceChain,
+					"verified": true,
+				},
 			})
 		}
 
@@ -85,11 +257,52 @@ func (a *API) reverseResolveHandler(w http.ResponseWriter, req bunrouter.Request
 		})
 	}
 
+	verified, err := a.verifyReverse(req.Context(), r.Address, name)
+	if err != nil {
+		a.logg.Error("forward verification failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
 	return httputil.JSON(w, http.StatusOK, OKResponse{
 		Ok:          true,
 		Description: "Name reverse resolved",
 		Result: map[string]any{
-			"name": name,
+			"name":     name,
+			"source":   sourceDB,
+			"verified": verified,
 		},
 	})
 }
+
+// upstreamUnavailable answers requests that fell back to ENS while no RPC endpoint answered, the name or
+// address may well exist.
+func (a *API) upstreamUnavailable(w http.ResponseWriter, err error) error {
+	a.logg.Error("upstream ENS resolution failed", "error", err)
+	return httputil.JSON(w, http.StatusServiceUnavailable, ErrResponse{
+		Ok:          false,
+		Description: "ENS resolution unavailable",
+	})
+}
+
+// verifyReverse reports whether the primary name of an address resolves back to it. Reverse results that do
+// not are flagged rather than hidden so support can spot and repair them.
+func (a *API) verifyReverse(ctx context.Context, address string, name string) (bool, error) {
+	resolved, err := a.store.LookupName(ctx, name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.logg.Warn("primary name does not resolve", "address", address, "name", name)
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !strings.EqualFold(resolved, address) {
+		a.logg.Warn("primary name resolves elsewhere", "address", address, "name", name, "resolved", resolved)
+		return false, nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/grassrootseconomics/ens-offchain-resolver/pkg/ens"
+	goens "github.com/grassrootseconomics/go-ens/v3"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestNameDetails(t *testing.T) {
+	const owner = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	f := newFakeStore()
+	f.renameGracePeriod = time.Hour
+	f.names["peter.sarafu.eth"] = &store.NameRecord{Name: "peter.sarafu.eth", Address: owner, Owner: owner, Kind: kindUser, Primary: true}
+	f.texts["peterk.sarafu.eth"] = map[string]string{
+		"url":              "https://example.com",
+		contenthashTextKey: "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+	}
+	if err := f.UpdateName(t.Context(), "peter.sarafu.eth", "peterk.sarafu.eth", owner); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAPI(f)
+	router := bunrouter.New()
+	router.GET("/resolve/:name/details", a.nameDetailsHandler)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resolve/Peter.sarafu.eth/details", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Result struct {
+			Name        string            `json:"name"`
+			Namehash    string            `json:"namehash"`
+			Labelhash   string            `json:"labelhash"`
+			Addresses   map[string]string `json:"addresses"`
+			Texts       map[string]string `json:"texts"`
+			Contenthash string            `json:"contenthash"`
+			Owner       string            `json:"owner"`
+			Primary     bool              `json:"primary"`
+			Source      string            `json:"source"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	details := resp.Result
+
+	// The old name in its rename grace period is answered with the current one.
+	if details.Name != "peterk.sarafu.eth" || details.Owner != owner || !details.Primary || details.Source != sourceDB {
+		t.Errorf("details = %+v, want the renamed name from the store", details)
+	}
+
+	nameHash, err := goens.NameHash("peterk.sarafu.eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	labelHash, err := goens.LabelHash("peterk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Namehash != hexutil.Encode(nameHash[:]) || details.Labelhash != hexutil.Encode(labelHash[:]) {
+		t.Errorf("hashes = %s, %s, want those of peterk.sarafu.eth", details.Namehash, details.Labelhash)
+	}
+
+	if details.Addresses["60"] != owner || details.Addresses["2147525868"] != owner {
+		t.Errorf("addresses = %v, want the Ethereum and Celo address", details.Addresses)
+	}
+	if details.Contenthash != "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi" {
+		t.Errorf("contenthash = %q", details.Contenthash)
+	}
+	if _, ok := details.Texts[contenthashTextKey]; ok || details.Texts["url"] != "https://example.com" {
+		t.Errorf("texts = %v, want them without the contenthash", details.Texts)
+	}
+}
+
+func TestReverseResolveVerified(t *testing.T) {
+	const (
+		alice   = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+		bob     = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+		unknown = "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB"
+	)
+
+	f := newFakeStore()
+	f.names["alice.sarafu.eth"] = &store.NameRecord{Name: "alice.sarafu.eth", Address: alice, Primary: true}
+	f.names["moved.sarafu.eth"] = &store.NameRecord{Name: "moved.sarafu.eth", Address: alice}
+
+	// No endpoint answers, so the ENS fallback is unavailable.
+	rpc := httptest.NewServer(http.NotFoundHandler())
+	rpc.Close()
+	ensProvider, err := ens.NewProvider(ens.ProviderOpts{
+		Logg:    slog.New(slog.DiscardHandler),
+		RPCURLs: []string{rpc.URL},
+		Timeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAPI(staleReverseStore{f, bob, "moved.sarafu.eth"})
+	a.ensProvider = ensProvider
+	router := bunrouter.New()
+	router.GET("/resolve/reverse/:address", a.reverseResolveHandler)
+
+	tests := []struct {
+		address  string
+		code     int
+		name     string
+		verified bool
+	}{
+		{address: alice, code: http.StatusOK, name: "alice.sarafu.eth", verified: true},
+		{address: bob, code: http.StatusOK, name: "moved.sarafu.eth", verified: false},
+		{address: unknown, code: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resolve/reverse/"+tt.address, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.address, rec.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var resp struct {
+			Result struct {
+				Name     string `json:"name"`
+				Source   string `json:"source"`
+				Verified bool   `json:"verified"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Result.Name != tt.name || resp.Result.Source != sourceDB || resp.Result.Verified != tt.verified {
+			t.Errorf("%s: result = %+v, want %s verified %v", tt.address, resp.Result, tt.name, tt.verified)
+		}
+	}
+}
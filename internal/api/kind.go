@@ -0,0 +1,205 @@
+package api
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	goens "github.com/grassrootseconomics/go-ens/v3"
+)
+
+const (
+	kindUser         = "user"
+	kindVoucher      = "voucher"
+	kindPool         = "pool"
+	kindOrganisation = "organisation"
+)
+
+type (
+	// kindSpec describes a category of names: the namespace its names live under and its standard text
+	// records. Other text records are allowed as well.
+	kindSpec struct {
+		namespace string
+		texts     map[string]textSpec
+	}
+
+	textSpec struct {
+		required bool
+		validate func(string) error
+	}
+)
+
+var (
+	// kindOrder lists kinds by namespace length, the other namespaces are nested under the user one.
+	kindOrder = []string{kindVoucher, kindPool, kindOrganisation, kindUser}
+
+	kinds = map[string]kindSpec{
+		kindUser: {
+			namespace: domainSuffix,
+			texts: map[string]textSpec{
+				"avatar":      {validate: validateURL},
+				"description": {},
+				"url":         {validate: validateURL},
+			},
+		},
+		kindVoucher: {
+			namespace: ".vouchers" + domainSuffix,
+			texts: map[string]textSpec{
+				"symbol":      {required: true, validate: validateSymbol},
+				"decimals":    {required: true, validate: validateDecimals},
+				"sink":        {validate: validateAddress},
+				"description": {},
+				"url":         {validate: validateURL},
+			},
+		},
+		kindPool: {
+			namespace: ".pools" + domainSuffix,
+			texts: map[string]textSpec{
+				"description": {},
+				"url":         {validate: validateURL},
+				"avatar":      {validate: validateURL},
+			},
+		},
+		kindOrganisation: {
+			namespace: ".orgs" + domainSuffix,
+			texts: map[string]textSpec{
+				"description": {},
+				"url":         {validate: validateURL},
+				"avatar":      {validate: validateURL},
+				"email":       {validate: validateEmail},
+			},
+		},
+	}
+
+	// commonTexts are standard records of every kind.
+	commonTexts = map[string]textSpec{
+		contenthashTextKey: {validate: validateContenthash},
+	}
+
+	validSymbol = regexp.MustCompile(`^[A-Za-z0-9]{1,16}$`)
+)
+
+// parseName splits a name into its label and kind, bare labels belong to defaultKind.
+func parseName(name string, defaultKind string) (string, string, error) {
+	name = strings.ToLower(name)
+
+	label, kind := name, defaultKind
+	for _, k := range kindOrder {
+		if l, ok := strings.CutSuffix(name, kinds[k].namespace); ok {
+			label, kind = l, k
+			break
+		}
+	}
+
+	if strings.Contains(label, ".") {
+		return "", "", fmt.Errorf("invalid ENS name")
+	}
+	if !isValidSubdomain(label) {
+		return "", "", fmt.Errorf("invalid subdomain format: only letters and numbers are allowed")
+	}
+	// The parents of the other namespaces must never be held by anyone, regardless of the name policy.
+	if kind == kindUser && isNamespaceLabel(label) {
+		return "", "", fmt.Errorf("name is reserved for a namespace")
+	}
+
+	return label, kind, nil
+}
+
+func isNamespaceLabel(label string) bool {
+	for k, spec := range kinds {
+		if k != kindUser && label+domainSuffix == strings.TrimPrefix(spec.namespace, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNameOfKind is parseName for endpoints that only accept names of a single kind.
+func parseNameOfKind(name string, kind string) (string, error) {
+	label, nameKind, err := parseName(name, kind)
+	if err != nil {
+		return "", err
+	}
+	if nameKind != kind {
+		return "", fmt.Errorf("name is outside the %s namespace %s", kind, kinds[kind].namespace)
+	}
+
+	return label, nil
+}
+
+func fullName(label string, kind string) string {
+	return label + kinds[kind].namespace
+}
+
+// validateTexts checks text records against the standard records of kind. Required records must be set
+// unless partial is true, as for updates of an existing name, where an empty value removes a record.
+func validateTexts(kind string, texts map[string]string, partial bool) error {
+	for key, spec := range commonTexts {
+		if value := texts[key]; value != "" && spec.validate != nil {
+			if err := spec.validate(value); err != nil {
+				return fmt.Errorf("invalid %s text record: %w", key, err)
+			}
+		}
+	}
+
+	for key, spec := range kinds[kind].texts {
+		value, ok := texts[key]
+		if spec.required && (ok || !partial) && value == "" {
+			return fmt.Errorf("%s names require the %s text record", kind, key)
+		}
+		if value != "" && spec.validate != nil {
+			if err := spec.validate(value); err != nil {
+				return fmt.Errorf("invalid %s text record: %w", key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateURL(v string) error {
+	u, err := url.ParseRequestURI(v)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http" && u.Scheme != "ipfs") {
+		return fmt.Errorf("must be an http(s) or ipfs URL")
+	}
+	return nil
+}
+
+func validateSymbol(v string) error {
+	if !validSymbol.MatchString(v) {
+		return fmt.Errorf("must be 1 to 16 letters or digits")
+	}
+	return nil
+}
+
+func validateDecimals(v string) error {
+	if d, err := strconv.Atoi(v); err != nil || d < 0 || d > 18 {
+		return fmt.Errorf("must be an integer between 0 and 18")
+	}
+	return nil
+}
+
+func validateAddress(v string) error {
+	if !common.IsHexAddress(v) {
+		return fmt.Errorf("must be an address")
+	}
+	return nil
+}
+
+func validateContenthash(v string) error {
+	if _, err := goens.StringToContenthash(v); err != nil {
+		return fmt.Errorf("must be an EIP-1577 content hash such as ipfs://<cid>")
+	}
+	return nil
+}
+
+func validateEmail(v string) error {
+	if _, err := mail.ParseAddress(v); err != nil {
+		return fmt.Errorf("must be an email address")
+	}
+	return nil
+}
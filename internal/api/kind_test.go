@@ -0,0 +1,65 @@
+package api
+
+import "testing"
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantLabel string
+		wantKind  string
+		wantErr   bool
+	}{
+		{input: "mama", wantLabel: "mama", wantKind: kindUser},
+		{input: "Mama.sarafu.eth", wantLabel: "mama", wantKind: kindUser},
+		{input: "cafe.vouchers.sarafu.eth", wantLabel: "cafe", wantKind: kindVoucher},
+		{input: "kibera.pools.sarafu.eth", wantLabel: "kibera", wantKind: kindPool},
+		{input: "ge.orgs.sarafu.eth", wantLabel: "ge", wantKind: kindOrganisation},
+		{input: "cafe.unknown.sarafu.eth", wantErr: true},
+		{input: "vouchers.sarafu.eth", wantErr: true},
+		{input: "pools", wantErr: true},
+		{input: "Orgs.sarafu.eth", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			label, kind, err := parseName(tt.input, kindUser)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseName(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if label != tt.wantLabel || kind != tt.wantKind {
+				t.Errorf("parseName(%q) = %q, %q, want %q, %q", tt.input, label, kind, tt.wantLabel, tt.wantKind)
+			}
+		})
+	}
+
+	if _, err := parseNameOfKind("cafe.sarafu.eth", kindVoucher); err == nil {
+		t.Error("expected a user name to be rejected for a voucher")
+	}
+}
+
+func TestValidateTexts(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		texts   map[string]string
+		partial bool
+		wantErr bool
+	}{
+		{name: "user without texts", kind: kindUser},
+		{name: "voucher missing decimals", kind: kindVoucher, texts: map[string]string{"symbol": "CAFE"}, wantErr: true},
+		{name: "voucher", kind: kindVoucher, texts: map[string]string{"symbol": "CAFE", "decimals": "6", "sink": "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439"}},
+		{name: "voucher bad decimals", kind: kindVoucher, texts: map[string]string{"symbol": "CAFE", "decimals": "six"}, wantErr: true},
+		{name: "voucher partial update", kind: kindVoucher, texts: map[string]string{"url": "https://cafe.example"}, partial: true},
+		{name: "voucher removing symbol", kind: kindVoucher, texts: map[string]string{"symbol": ""}, partial: true, wantErr: true},
+		{name: "custom record", kind: kindPool, texts: map[string]string{"com.twitter": "kibera"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTexts(tt.kind, tt.texts, tt.partial)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTexts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,5 +1,11 @@
 package api
 
+import (
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/policy"
+)
+
 type (
 	OKResponse struct {
 		Ok          bool           `json:"ok"`
@@ -12,6 +18,12 @@ type (
 		Description string `json:"description"`
 	}
 
+	PolicyErrResponse struct {
+		Ok          bool               `json:"ok"`
+		Description string             `json:"description"`
+		Violations  []policy.Violation `json:"violations"`
+	}
+
 	CCIPOKResponse struct {
 		Data string `json:"data"`
 	}
@@ -21,17 +33,129 @@ type (
 	}
 
 	RegisterRequest struct {
-		Address string `json:"address" validate:"required,eth_addr_checksum"`
-		Hint    string `json:"hint" validate:"required,fqdn"`
+		Address          string `json:"address" validate:"required,eth_addr_checksum"`
+		Hint             string `json:"hint" validate:"required,fqdn"`
+		ReservationToken string `json:"reservationToken" validate:"omitempty,hexadecimal"`
+		Kind             string `json:"kind" validate:"omitempty,oneof=user voucher pool organisation"`
+		// Texts are the initial text records, vouchers require symbol and decimals.
+		Texts map[string]string `json:"texts" validate:"omitempty,max=20,dive,keys,required,max=64,endkeys,max=512"`
 	}
 
 	UpdateRequest struct {
-		Name    string `json:"name" validate:"required,fqdn"`
+		Name string `json:"name" validate:"required,fqdn"`
+		// Address is the owner of the name, Current the name to rename which defaults to its primary name.
 		Address string `json:"address" validate:"required,eth_addr_checksum"`
+		Current string `json:"current" validate:"omitempty,fqdn"`
 	}
 
 	UpsertRequest struct {
 		Name    string `json:"name" validate:"required,fqdn"`
 		Address string `json:"address" validate:"required,eth_addr_checksum"`
 	}
+
+	SetAddressRequest struct {
+		Name    string `json:"name" validate:"required,fqdn"`
+		Owner   string `json:"owner" validate:"required,eth_addr_checksum"`
+		Address string `json:"address" validate:"required,eth_addr_checksum"`
+	}
+
+	CreateTransferRequest struct {
+		Name string `json:"name" validate:"required,fqdn"`
+		From string `json:"from" validate:"required,eth_addr_checksum"`
+		To   string `json:"to" validate:"required,eth_addr_checksum,nefield=From"`
+	}
+
+	RenewRequest struct {
+		Name string `json:"name" validate:"required,fqdn"`
+	}
+
+	SetPrimaryRequest struct {
+		Name    string `json:"name" validate:"required,fqdn"`
+		Address string `json:"address" validate:"required,eth_addr_checksum"`
+	}
+
+	CreateAPIKeyRequest struct {
+		Name      string     `json:"name" validate:"required,max=64"`
+		Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=names:read names:write admin"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+
+	SelfChallengeRequest struct {
+		Name    string            `json:"name" validate:"required,fqdn"`
+		NewName string            `json:"newName" validate:"omitempty,fqdn"`
+		Address string            `json:"address" validate:"omitempty,eth_addr_checksum"`
+		Texts   map[string]string `json:"texts" validate:"omitempty,max=20,dive,keys,required,max=64,endkeys,max=512"`
+		// AcceptTransfer is the ID of a pending transfer of the name to be accepted by its recipient.
+		AcceptTransfer int `json:"acceptTransfer" validate:"omitempty,min=1"`
+	}
+
+	SelfConfirmRequest struct {
+		Message   string `json:"message" validate:"required,max=4096"`
+		Signature string `json:"signature" validate:"required"`
+	}
+
+	// SelfAction is the change a SIWE challenge authorizes once signed.
+	SelfAction struct {
+		NewName        string            `json:"newName,omitempty"`
+		Address        string            `json:"address,omitempty"`
+		Texts          map[string]string `json:"texts,omitempty"`
+		AcceptTransfer int               `json:"acceptTransfer,omitempty"`
+	}
+
+	ReserveRequest struct {
+		Name string `json:"name" validate:"required,fqdn"`
+		Kind string `json:"kind" validate:"omitempty,oneof=user voucher pool organisation"`
+		// TTL in seconds, defaults to the configured reservation TTL.
+		TTL int `json:"ttl" validate:"omitempty,min=1,max=86400"`
+	}
+
+	BulkRegisterRequest struct {
+		// Mode is per_row (default), registering what it can, or atomic, registering all entries or none.
+		Mode string `json:"mode" validate:"omitempty,oneof=per_row atomic"`
+		// Entries are validated one by one so that a bad row only fails itself.
+		Entries []RegisterRequest `json:"entries" validate:"required,min=1"`
+	}
+
+	BulkReport struct {
+		Mode       string       `json:"mode"`
+		Committed  bool         `json:"committed"`
+		Registered int          `json:"registered"`
+		Renamed    int          `json:"renamed"`
+		Existing   int          `json:"existing"`
+		Failed     int          `json:"failed"`
+		Results    []BulkResult `json:"results"`
+	}
+
+	// BulkResult is the outcome of one bulk entry, Name is the registered or already held name.
+	BulkResult struct {
+		Index   int    `json:"index"`
+		Address string `json:"address"`
+		Hint    string `json:"hint"`
+		Name    string `json:"name,omitempty"`
+		Status  string `json:"status"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	BatchResolveRequest struct {
+		Names []string `json:"names" validate:"required,min=1,dive,required,max=255"`
+	}
+
+	BatchReverseResolveRequest struct {
+		Addresses []string `json:"addresses" validate:"required,min=1,dive,eth_addr_checksum"`
+	}
+
+	// BatchResult is one item of a batch resolution in request order, Found is false when it did not resolve.
+	// Verified is only set for found reverse results and tells whether the name resolves back to the address.
+	BatchResult struct {
+		Name     string `json:"name,omitempty"`
+		Address  string `json:"address,omitempty"`
+		Found    bool   `json:"found"`
+		Verified *bool  `json:"verified,omitempty"`
+	}
+
+	AddPolicyEntryRequest struct {
+		List        string `json:"list" validate:"required,oneof=reserved blocked regex"`
+		Value       string `json:"value" validate:"required,max=255"`
+		Description string `json:"description" validate:"max=255"`
+	}
 )
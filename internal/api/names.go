@@ -0,0 +1,395 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	defaultListNamesLimit = 50
+	maxListNamesLimit     = 200
+
+	// cursorTimeLayout matches how TIMESTAMP columns are compared, without a zone and to the microsecond.
+	cursorTimeLayout = "2006-01-02 15:04:05.999999"
+)
+
+// listCursor is the opaque cursor of listNamesHandler, it is only valid for the sort it was issued for.
+type listCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d"`
+	store.NameCursor
+}
+
+// listNamesHandler lets support search all names, page by page. Pass nextCursor of a page as cursor to get
+// the next one with the same filters and sort.
+func (a *API) listNamesHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	filter, err := parseListNamesQuery(req.URL.Query())
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+
+	limit := filter.Limit
+	// One extra name tells whether there is a next page.
+	filter.Limit++
+
+	names, err := a.store.ListNames(req.Context(), filter)
+	if err != nil {
+		a.logg.Error("list names failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	nextCursor := ""
+	if len(names) > limit {
+		names = names[:limit]
+		nextCursor = encodeListCursor(filter, names[limit-1])
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Names",
+		Result: map[string]any{
+			"names":      names,
+			"nextCursor": nextCursor,
+		},
+	})
+}
+
+func parseListNamesQuery(q url.Values) (store.NameFilter, error) {
+	filter := store.NameFilter{
+		Prefix:   strings.ToLower(q.Get("prefix")),
+		Contains: strings.ToLower(q.Get("q")),
+		Tenant:   q.Get("tenant"),
+		Kind:     q.Get("kind"),
+		Sort:     q.Get("sort"),
+		Limit:    defaultListNamesLimit,
+	}
+
+	if _, ok := kinds[filter.Kind]; filter.Kind != "" && !ok {
+		return filter, errors.New("unknown kind")
+	}
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = store.NameSortName
+	case store.NameSortName, store.NameSortCreatedAt, store.NameSortUpdatedAt:
+	default:
+		return filter, errors.New("sort must be name, created_at or updated_at")
+	}
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return filter, errors.New("order must be asc or desc")
+	}
+
+	// Support mostly looks for live names, inactive ones have to be asked for.
+	active := true
+	filter.Active = &active
+	switch q.Get("active") {
+	case "", "true":
+	case "false":
+		active = false
+	case "any":
+		filter.Active = nil
+	default:
+		return filter, errors.New("active must be true, false or any")
+	}
+
+	for param, target := range map[string]**time.Time{
+		"createdAfter":  &filter.CreatedAfter,
+		"createdBefore": &filter.CreatedBefore,
+		"updatedAfter":  &filter.UpdatedAfter,
+		"updatedBefore": &filter.UpdatedBefore,
+	} {
+		if v := q.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", param)
+			}
+			*target = &t
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListNamesLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxListNamesLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := decodeListCursor(v)
+		if err != nil {
+			return filter, errors.New("invalid cursor")
+		}
+		if cursor.Sort != filter.Sort || cursor.Descending != filter.Descending {
+			return filter, errors.New("cursor was issued for a different sort")
+		}
+		if filter.Sort != store.NameSortName {
+			if _, err := time.Parse(cursorTimeLayout, cursor.Value); err != nil {
+				return filter, errors.New("invalid cursor")
+			}
+		}
+		filter.After = &cursor.NameCursor
+	}
+
+	return filter, nil
+}
+
+func encodeListCursor(filter store.NameFilter, last store.ListedName) string {
+	cursor := listCursor{
+		Sort:       filter.Sort,
+		Descending: filter.Descending,
+		NameCursor: store.NameCursor{
+			Value: last.Name,
+			ID:    last.ID,
+		},
+	}
+	switch filter.Sort {
+	case store.NameSortCreatedAt:
+		cursor.Value = last.CreatedAt.Format(cursorTimeLayout)
+	case store.NameSortUpdatedAt:
+		cursor.Value = last.UpdatedAt.Format(cursorTimeLayout)
+	}
+
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeListCursor(s string) (listCursor, error) {
+	var cursor listCursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return cursor, err
+	}
+
+	return cursor, nil
+}
+
+func (a *API) listAddressNamesHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := PublicAddressParam{
+		Address: req.Param("address"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Address validation failed",
+		})
+	}
+
+	kind := req.URL.Query().Get("kind")
+	if _, ok := kinds[kind]; kind != "" && !ok {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Unknown kind",
+		})
+	}
+
+	names, err := a.store.ListAddressNames(req.Context(), r.Address, kind)
+	if err != nil {
+		a.logg.Error("list address names failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Address names",
+		Result: map[string]any{
+			"address": r.Address,
+			"names":   names,
+		},
+	})
+}
+
+// setPrimaryNameHandler picks which of the names of an address is returned by reverse resolution.
+func (a *API) setPrimaryNameHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var primaryReq SetPrimaryRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &primaryReq); err != nil {
+		a.logg.Error("validation failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	label, kind, err := parseName(primaryReq.Name, kindUser)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+
+	normalizedName := fullName(label, kind)
+
+	if err := a.store.SetPrimaryName(req.Context(), normalizedName, primaryReq.Address); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Name is not registered to this address",
+			})
+		}
+
+		a.logg.Error("set primary name failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Primary name updated",
+		Result: map[string]any{
+			"name":    normalizedName,
+			"address": primaryReq.Address,
+		},
+	})
+}
+
+// setNameAddressHandler changes where a name resolves to, only its owner may do so.
+func (a *API) setNameAddressHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var addressReq SetAddressRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &addressReq); err != nil {
+		a.logg.Error("validation failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	label, kind, err := parseName(addressReq.Name, kindUser)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+
+	normalizedName := fullName(label, kind)
+
+	if err := a.store.SetNameAddress(req.Context(), normalizedName, addressReq.Owner, addressReq.Address); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Name not found",
+			})
+		}
+
+		if errors.Is(err, store.ErrNotOwner) {
+			return httputil.JSON(w, http.StatusForbidden, ErrResponse{
+				Ok:          false,
+				Description: "Name is not owned by this owner",
+			})
+		}
+
+		a.logg.Error("set name address failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name address updated",
+		Result: map[string]any{
+			"name":    normalizedName,
+			"owner":   addressReq.Owner,
+			"address": addressReq.Address,
+		},
+	})
+}
+
+// renewHandler extends the lease of a name, names of kinds without a lease never expire.
+func (a *API) renewHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var renewReq RenewRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &renewReq); err != nil {
+		a.logg.Error("validation failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	label, kind, err := parseName(renewReq.Name, kindUser)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+
+	record, err := a.store.LookupNameRecord(req.Context(), fullName(label, kind))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Name not found",
+			})
+		}
+
+		a.logg.Error("lookup failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	expiresAt, err := a.store.RenewName(req.Context(), record.Name, record.Kind)
+	if err != nil {
+		if errors.Is(err, store.ErrNoLease) {
+			return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+				Ok:          false,
+				Description: "Name does not expire",
+			})
+		}
+
+		a.logg.Error("renew failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name renewed",
+		Result: map[string]any{
+			"name":      record.Name,
+			"expiresAt": expiresAt,
+		},
+	})
+}
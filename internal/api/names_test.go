@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/policy"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestParseListNamesQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+		check   func(t *testing.T, f store.NameFilter)
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			check: func(t *testing.T, f store.NameFilter) {
+				if f.Sort != store.NameSortName || f.Descending || f.Limit != defaultListNamesLimit {
+					t.Errorf("unexpected defaults %+v", f)
+				}
+				if f.Active == nil || !*f.Active {
+					t.Errorf("active names only by default, got %v", f.Active)
+				}
+			},
+		},
+		{
+			name:  "filters",
+			query: "prefix=Pet&q=ER&kind=voucher&tenant=apikey:1&active=any&createdAfter=2025-01-01T00:00:00Z&sort=created_at&order=desc&limit=10",
+			check: func(t *testing.T, f store.NameFilter) {
+				if f.Prefix != "pet" || f.Contains != "er" || f.Kind != "voucher" || f.Tenant != "apikey:1" {
+					t.Errorf("unexpected filter %+v", f)
+				}
+				if f.Active != nil {
+					t.Errorf("active=any should not filter, got %v", *f.Active)
+				}
+				if f.CreatedAfter == nil || !f.CreatedAfter.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("unexpected createdAfter %v", f.CreatedAfter)
+				}
+				if f.Sort != store.NameSortCreatedAt || !f.Descending || f.Limit != 10 {
+					t.Errorf("unexpected sort %+v", f)
+				}
+			},
+		},
+		{name: "unknown kind", query: "kind=shop", wantErr: true},
+		{name: "unknown sort", query: "sort=address", wantErr: true},
+		{name: "limit too large", query: "limit=1000", wantErr: true},
+		{name: "bad timestamp", query: "updatedBefore=yesterday", wantErr: true},
+		{name: "bad cursor", query: "cursor=not-a-cursor", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			f, err := parseListNamesQuery(q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseListNamesQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, f)
+			}
+		})
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	last := store.ListedName{
+		ID:        42,
+		Name:      "peter.sarafu.eth",
+		CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 123456000, time.UTC),
+	}
+
+	filter, _ := parseListNamesQuery(url.Values{"sort": {"created_at"}})
+	cursor := encodeListCursor(filter, last)
+
+	next, err := parseListNamesQuery(url.Values{"sort": {"created_at"}, "cursor": {cursor}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.After == nil || next.After.ID != 42 || next.After.Value != "2025-01-02 03:04:05.123456" {
+		t.Errorf("unexpected cursor %+v", next.After)
+	}
+
+	if _, err := parseListNamesQuery(url.Values{"sort": {"name"}, "cursor": {cursor}}); err == nil {
+		t.Error("cursor accepted for a different sort")
+	}
+}
+
+func TestNameLeases(t *testing.T) {
+	const owner = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	now := time.Now()
+	expiring := func(d time.Duration) *time.Time {
+		expiresAt := now.Add(d)
+		return &expiresAt
+	}
+
+	f := newFakeStore()
+	f.leases = map[string]time.Duration{kindUser: 24 * time.Hour}
+	f.expiryGracePeriod = time.Hour
+	for name, expiresAt := range map[string]*time.Time{
+		"live.sarafu.eth":     expiring(time.Hour),
+		"grace.sarafu.eth":    expiring(-30 * time.Minute),
+		"released.sarafu.eth": expiring(-2 * time.Hour),
+		"forever.sarafu.eth":  nil,
+	} {
+		f.names[name] = &store.NameRecord{Name: name, Address: owner, Owner: owner, Kind: kindUser, ExpiresAt: expiresAt}
+	}
+
+	a := newTestAPI(f)
+	a.policy = policy.Default()
+
+	renew := func(name string) (int, time.Time) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"`+name+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		if err := a.renewHandler(rec, bunrouter.NewRequest(req)); err != nil {
+			t.Fatal(err)
+		}
+
+		var resp struct {
+			Result struct {
+				ExpiresAt time.Time `json:"expiresAt"`
+			} `json:"result"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Result.ExpiresAt
+	}
+
+	t.Run("resolves as expired during the grace period", func(t *testing.T) {
+		record, err := f.LookupNameRecord(context.Background(), "grace.sarafu.eth")
+		if err != nil || !record.Expired {
+			t.Errorf("LookupNameRecord() = %+v, %v, want an expired record", record, err)
+		}
+
+		expires, err := a.resolveText(context.Background(), "grace.sarafu.eth", expiryTextKey)
+		if err != nil || expires != strconv.FormatInt(f.names["grace.sarafu.eth"].ExpiresAt.Unix(), 10) {
+			t.Errorf("expires text = %q, %v", expires, err)
+		}
+	})
+
+	t.Run("renewal extends a live lease", func(t *testing.T) {
+		code, expiresAt := renew("live.sarafu.eth")
+		if code != http.StatusOK || expiresAt.Before(now.Add(25*time.Hour-time.Minute)) {
+			t.Errorf("renew = %d, %v, want the lease added to the current expiry", code, expiresAt)
+		}
+	})
+
+	t.Run("renewal during the grace period starts from now", func(t *testing.T) {
+		code, expiresAt := renew("grace.sarafu.eth")
+		if code != http.StatusOK || expiresAt.Before(now.Add(24*time.Hour)) || expiresAt.After(now.Add(25*time.Hour)) {
+			t.Errorf("renew = %d, %v, want a lease from now", code, expiresAt)
+		}
+	})
+
+	t.Run("names without expiry are not renewed", func(t *testing.T) {
+		if code, _ := renew("forever.sarafu.eth"); code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("released names are gone and free again", func(t *testing.T) {
+		if code, _ := renew("released.sarafu.eth"); code != http.StatusNotFound {
+			t.Errorf("renew status = %d, want %d", code, http.StatusNotFound)
+		}
+		if _, err := a.resolveText(context.Background(), "released.sarafu.eth", expiryTextKey); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("expires text error = %v, want %v", err, pgx.ErrNoRows)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"address":"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359","hint":"released.sarafu.eth"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		if err := a.registerHandler(rec, bunrouter.NewRequest(req)); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"released.sarafu.eth"`) {
+			t.Errorf("register = %d %s, want the released name", rec.Code, rec.Body)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/policy"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+// ReloadPolicy applies the runtime managed policy entries from the store. It is safe to call concurrently
+// with requests and is run periodically so that every instance picks up admin changes.
+func (a *API) ReloadPolicy(ctx context.Context) (int64, error) {
+	entries, err := a.store.ListPolicyEntries(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		reserved []string
+		blocked  []string
+		rules    []policy.Rule
+	)
+	for _, e := range entries {
+		switch e.List {
+		case "reserved":
+			reserved = append(reserved, e.Value)
+		case "blocked":
+			blocked = append(blocked, e.Value)
+		case "regex":
+			rules = append(rules, policy.Rule{
+				Name:        "regex:" + strconv.Itoa(e.ID),
+				Pattern:     e.Value,
+				Description: e.Description,
+			})
+		}
+	}
+
+	if err := a.policy.SetDynamic(reserved, blocked, rules); err != nil {
+		return 0, err
+	}
+
+	return int64(len(entries)), nil
+}
+
+// rejectByPolicy writes a 400 listing the violations when the label is not allowed.
+func (a *API) rejectByPolicy(w http.ResponseWriter, label string) (bool, error) {
+	violations := a.policy.Check(label)
+	if len(violations) == 0 {
+		return false, nil
+	}
+
+	return true, httputil.JSON(w, http.StatusBadRequest, PolicyErrResponse{
+		Ok:          false,
+		Description: "Name is not allowed",
+		Violations:  violations,
+	})
+}
+
+func (a *API) listPolicyEntriesHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	entries, err := a.store.ListPolicyEntries(req.Context())
+	if err != nil {
+		a.logg.Error("list policy entries failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Policy entries",
+		Result: map[string]any{
+			"entries": entries,
+		},
+	})
+}
+
+func (a *API) addPolicyEntryHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var addReq AddPolicyEntryRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &addReq); err != nil {
+		a.logg.Error("validation failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	entry := store.PolicyEntry{
+		List:        addReq.List,
+		Value:       addReq.Value,
+		Description: addReq.Description,
+	}
+	if entry.List == "regex" {
+		if _, err := regexp.Compile(entry.Value); err != nil {
+			return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+				Ok:          false,
+				Description: "Invalid regex: " + err.Error(),
+			})
+		}
+	} else {
+		entry.Value = strings.ToLower(strings.TrimSuffix(entry.Value, domainSuffix))
+	}
+
+	if err := a.store.AddPolicyEntry(req.Context(), &entry); err != nil {
+		if isUniqueViolation(err) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Policy entry already exists",
+			})
+		}
+
+		a.logg.Error("add policy entry failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	if _, err := a.ReloadPolicy(req.Context()); err != nil {
+		a.logg.Error("policy reload failed", "error", err)
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Policy entry added",
+		Result: map[string]any{
+			"entry": entry,
+		},
+	})
+}
+
+func (a *API) deletePolicyEntryHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	id, err := strconv.Atoi(req.Param("id"))
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid policy entry id",
+		})
+	}
+
+	deleted, err := a.store.DeletePolicyEntry(req.Context(), id)
+	if err != nil {
+		a.logg.Error("delete policy entry failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	if !deleted {
+		return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+			Ok:          false,
+			Description: "Policy entry not found",
+		})
+	}
+
+	if _, err := a.ReloadPolicy(req.Context()); err != nil {
+		a.logg.Error("policy reload failed", "error", err)
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Policy entry deleted",
+		Result: map[string]any{
+			"id": id,
+		},
+	})
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+	"golang.org/x/time/rate"
+)
+
+type (
+	// RateLimitOpts configures the token bucket of a route group, a zero RPS disables limiting.
+	RateLimitOpts struct {
+		RPS   float64
+		Burst int
+	}
+
+	rateLimiter struct {
+		mu        sync.Mutex
+		limit     rate.Limit
+		burst     int
+		buckets   map[string]*bucket
+		lastSweep time.Time
+		rejected  *metrics.Counter
+	}
+
+	bucket struct {
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+)
+
+const (
+	rateLimitGroupResolve  = "resolve"
+	rateLimitGroupCCIP     = "ccip"
+	rateLimitGroupSelf     = "self"
+	rateLimitGroupInternal = "internal"
+	// rateLimitGroupAuth throttles internal routes per client IP before credentials are checked.
+	rateLimitGroupAuth = "auth"
+	// rateLimitGroupBatch charges batch resolve requests one token per item.
+	rateLimitGroupBatch = "batch"
+
+	bucketIdleTimeout = 10 * time.Minute
+)
+
+func newRateLimiter(group string, o RateLimitOpts) *rateLimiter {
+	if o.RPS <= 0 {
+		return nil
+	}
+
+	burst := o.Burst
+	if burst < 1 {
+		burst = int(math.Ceil(o.RPS))
+	}
+
+	return &rateLimiter{
+		limit:     rate.Limit(o.RPS),
+		burst:     burst,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		rejected:  metrics.GetOrCreateCounter(fmt.Sprintf(`ratelimit_rejected_total{group=%q}`, group)),
+	}
+}
+
+func (rl *rateLimiter) allow(key string) bool {
+	return rl.allowN(key, 1)
+}
+
+func (rl *rateLimiter) allowN(key string, n int) bool {
+	now := time.Now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// Drop idle buckets so that the map does not grow with every client ever seen.
+	if now.Sub(rl.lastSweep) > bucketIdleTimeout {
+		for k, b := range rl.buckets {
+			if now.Sub(b.lastSeen) > bucketIdleTimeout {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	return b.limiter.AllowN(now, n)
+}
+
+// tooManyRequests rejects a request that needed n tokens.
+func (rl *rateLimiter) tooManyRequests(w http.ResponseWriter, n int) error {
+	rl.rejected.Inc()
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(float64(n)/float64(rl.limit)))))
+	return httputil.JSON(w, http.StatusTooManyRequests, ErrResponse{
+		Ok:          false,
+		Description: "Too many requests",
+	})
+}
+
+// rateLimitMiddleware throttles requests per key, keyFn returning an empty key skips limiting.
+func (a *API) rateLimitMiddleware(rl *rateLimiter, keyFn func(bunrouter.Request) string) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		if rl == nil {
+			return next
+		}
+
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			if key := keyFn(req); key != "" && !rl.allow(key) {
+				return rl.tooManyRequests(w, 1)
+			}
+
+			return next(w, req)
+		}
+	}
+}
+
+func (a *API) clientIPKey(req bunrouter.Request) string {
+	return clientIP(req.Request, a.trustedProxies).String()
+}
+
+func subjectKey(req bunrouter.Request) string {
+	if p := principalFromContext(req.Context()); p != nil {
+		return p.Subject
+	}
+	return ""
+}
+
+// clientIP walks X-Forwarded-For from the right, skipping trusted proxies, so that a client cannot spoof
+// its address by prepending entries to the header.
+func clientIP(req *http.Request, trustedProxies []netip.Prefix) netip.Addr {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	remote = remote.Unmap()
+
+	if !isTrustedProxy(remote, trustedProxies) {
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		hop = hop.Unmap()
+		if !isTrustedProxy(hop, trustedProxies) {
+			return hop
+		}
+		remote = hop
+	}
+
+	return remote
+}
+
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies accepts both CIDR ranges and single IP addresses.
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, e := range entries {
+		if strings.Contains(e, "/") {
+			p, err := netip.ParsePrefix(e)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(e)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+
+	return prefixes, nil
+}
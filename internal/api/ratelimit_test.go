@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expected     string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:4000",
+			expected:   "203.0.113.7",
+		},
+		{
+			name:         "untrusted remote cannot spoof",
+			remoteAddr:   "203.0.113.7:4000",
+			forwardedFor: "198.51.100.1",
+			expected:     "203.0.113.7",
+		},
+		{
+			name:         "behind trusted proxies",
+			remoteAddr:   "10.0.0.2:4000",
+			forwardedFor: "198.51.100.99, 198.51.100.1, 192.168.1.1",
+			expected:     "198.51.100.1",
+		},
+		{
+			name:         "only trusted hops",
+			remoteAddr:   "10.0.0.2:4000",
+			forwardedFor: "10.0.0.3",
+			expected:     "10.0.0.3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			if got := clientIP(req, trusted).String(); got != tt.expected {
+				t.Errorf("clientIP() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	rl := newRateLimiter("test", RateLimitOpts{RPS: 1, Burst: 2})
+
+	for i, expected := range []bool{true, true, false} {
+		if got := rl.allow("client"); got != expected {
+			t.Errorf("request %d: allow() = %v, want %v", i, got, expected)
+		}
+	}
+
+	if !rl.allow("other-client") {
+		t.Error("buckets should be independent per key")
+	}
+}
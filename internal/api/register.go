@@ -3,21 +3,33 @@ package api
 import (
 	"context"
 	"errors"
-	"fmt"
-	"math/rand/v2"
 	"net/http"
 	"regexp"
-	"strings"
 
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/namegen"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/kamikazechaser/common/httputil"
 	"github.com/uptrace/bunrouter"
 )
 
-const domainSuffix = ".sarafu.eth"
+const (
+	domainSuffix = ".sarafu.eth"
 
-var validSubdomain = regexp.MustCompile(`^[a-z][a-z0-9-]*[a-z0-9]$|^[a-z]$`)
+	// autoChooseRounds bounds the queries of a single request, the random strategy alone proposes 90 two digit
+	// and 900 three digit suffixes within that many rounds.
+	autoChooseRounds    = 40
+	autoChooseBatchSize = 30
+)
+
+var (
+	validSubdomain = regexp.MustCompile(`^[a-z][a-z0-9-]*[a-z0-9]$|^[a-z]$`)
+
+	errAutoChooseExhausted = errors.New("autochoose exhausted all candidates")
+
+	defaultAutoChooseStrategies = []string{"random", "sequential"}
+)
 
 func (a *API) registerHandler(w http.ResponseWriter, req bunrouter.Request) error {
 	var registerReq RegisterRequest
@@ -30,7 +42,7 @@ func (a *API) registerHandler(w http.ResponseWriter, req bunrouter.Request) erro
 		})
 	}
 
-	subdomain, err := extractSubdomain(registerReq.Hint)
+	subdomain, kind, err := normalizeRegistration(registerReq)
 	if err != nil {
 		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
 			Ok:          false,
@@ -38,12 +50,33 @@ func (a *API) registerHandler(w http.ResponseWriter, req bunrouter.Request) erro
 		})
 	}
 
-	normalizedHint := subdomain + domainSuffix
+	normalizedHint := fullName(subdomain, kind)
+
+	if rejected, err := a.rejectByPolicy(w, subdomain); rejected {
+		return err
+	}
+
+	if registerReq.ReservationToken != "" {
+		return a.registerReserved(req.Context(), normalizedHint, kind, registerReq, w)
+	}
 
 	_, err = a.store.LookupName(req.Context(), normalizedHint)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			if err := a.store.RegisterName(req.Context(), normalizedHint, registerReq.Address); err != nil {
+			err := a.store.RegisterName(req.Context(), store.NewName{
+				Name:    normalizedHint,
+				Address: registerReq.Address,
+				Kind:    kind,
+				Texts:   registerReq.Texts,
+				Tenant:  tenantFromContext(req.Context()),
+			})
+			if err != nil {
+				// Someone else holds the hint for now or registered it since the lookup, treat it like a
+				// taken name.
+				if errors.Is(err, store.ErrNameReserved) || isUniqueViolation(err) {
+					return a.autoChoose(req.Context(), subdomain, kind, registerReq, w)
+				}
+
 				a.logg.Error("register failed", "error", err)
 				return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
 					Ok:          false,
@@ -69,48 +102,163 @@ func (a *API) registerHandler(w http.ResponseWriter, req bunrouter.Request) erro
 		}
 	}
 
-	return a.autoChoose(req.Context(), subdomain, registerReq.Address, w)
+	return a.autoChoose(req.Context(), subdomain, kind, registerReq, w)
+}
+
+// normalizeRegistration returns the label and kind of a registration request and checks its text records.
+func normalizeRegistration(registerReq RegisterRequest) (string, string, error) {
+	kind := registerReq.Kind
+	if kind == "" {
+		kind = kindUser
+	}
+
+	label, err := parseNameOfKind(registerReq.Hint, kind)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := validateTexts(kind, registerReq.Texts, false); err != nil {
+		return "", "", err
+	}
+
+	return label, kind, nil
+}
+
+// registerReserved registers exactly the reserved name, a reservation is never swapped for an autoChoose
+// alternative.
+func (a *API) registerReserved(ctx context.Context, name string, kind string, registerReq RegisterRequest, w http.ResponseWriter) error {
+	err := a.store.RegisterReservedName(ctx, store.NewName{
+		Name:    name,
+		Address: registerReq.Address,
+		Kind:    kind,
+		Texts:   registerReq.Texts,
+		Tenant:  tenantFromContext(ctx),
+	}, registerReq.ReservationToken)
+	if err != nil {
+		if errors.Is(err, store.ErrReservationNotFound) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Reservation not found or expired",
+			})
+		}
+
+		if isUniqueViolation(err) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Name already taken",
+			})
+		}
+
+		a.logg.Error("register reserved failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name registered",
+		Result: map[string]any{
+			"address":    registerReq.Address,
+			"name":       name,
+			"autoChoose": false,
+		},
+	})
+}
+
+// autoChoose registers an alternative for a taken user name. Vouchers, pools and organisations are
+// registered under their exact name only.
+func (a *API) autoChoose(ctx context.Context, subdomain string, kind string, registerReq RegisterRequest, w http.ResponseWriter) error {
+	if kind != kindUser {
+		return httputil.JSON(w, http.StatusConflict, ErrResponse{
+			Ok:          false,
+			Description: "Name already taken",
+		})
+	}
+
+	address := registerReq.Address
+	name, err := a.registerAutoChosen(ctx, subdomain, store.NewName{
+		Address: address,
+		Texts:   registerReq.Texts,
+		Tenant:  tenantFromContext(ctx),
+	})
+	if err != nil {
+		if errors.Is(err, errAutoChooseExhausted) {
+			return httputil.JSON(w, http.StatusServiceUnavailable, ErrResponse{
+				Ok:          false,
+				Description: "Autochoose error, try a different hint",
+			})
+		}
+
+		if isUniqueViolation(err) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Concurrent registration for this address, retry",
+			})
+		}
+
+		a.logg.Error("register failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name registered",
+		Result: map[string]any{
+			"address":    address,
+			"name":       name,
+			"autoChoose": true,
+		},
+	})
 }
 
-func (a *API) autoChoose(ctx context.Context, subdomain string, address string, w http.ResponseWriter) error {
-	// Max of 90 iterations to find the first available alias + suffix
-	for i := 0; i < 90; i++ {
-		a.logg.Debug("autochoose iteration", "iteration", i, "subdomain", subdomain)
-		num := rand.IntN(90) + 10
-		randName := fmt.Sprintf("%s%d", subdomain, num)
-		_, err := a.store.LookupName(ctx, randName)
+// registerAutoChosen walks the configured strategies round by round. Each round is a single statement that
+// skips taken candidates and registers the first free one for name, so concurrent registrations cannot collide.
+func (a *API) registerAutoChosen(ctx context.Context, subdomain string, name store.NewName) (string, error) {
+	for round := range autoChooseRounds {
+		candidates, ok := a.autoChooseCandidates(subdomain, round)
+		if !ok {
+			break
+		}
+		a.logg.Debug("autochoose round", "round", round, "subdomain", subdomain, "candidates", len(candidates))
+		if len(candidates) == 0 {
+			continue
+		}
+
+		registered, err := a.store.RegisterFirstAvailable(ctx, candidates, name)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
-				if err := a.store.RegisterName(ctx, randName+domainSuffix, address); err != nil {
-					var pgErr *pgconn.PgError
-					if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-						continue
-					}
-
-					a.logg.Error("register failed", "error", err)
-					return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
-						Ok:          false,
-						Description: "Internal server error",
-					})
-				}
-
-				return httputil.JSON(w, http.StatusOK, OKResponse{
-					Ok:          true,
-					Description: "Name registered",
-					Result: map[string]any{
-						"address":    address,
-						"name":       randName + domainSuffix,
-						"autoChoose": true,
-					},
-				})
+				continue
 			}
+			return "", err
 		}
+
+		return registered, nil
 	}
 
-	return httputil.JSON(w, http.StatusServiceUnavailable, ErrResponse{
-		Ok:          false,
-		Description: "Autochoose error, try a different hint",
-	})
+	return "", errAutoChooseExhausted
+}
+
+// autoChooseCandidates returns the names of a round that the policy allows, ok is false once the strategies
+// have nothing left to generate.
+func (a *API) autoChooseCandidates(subdomain string, round int) ([]string, bool) {
+	labels := namegen.Generate(a.autoChooseStrategies, subdomain, round, autoChooseBatchSize)
+	if len(labels) == 0 {
+		return nil, false
+	}
+
+	candidates := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if len(a.policy.Check(label)) == 0 && !isNamespaceLabel(label) {
+			candidates = append(candidates, label+domainSuffix)
+		}
+	}
+
+	return candidates, true
 }
 
 func (a *API) updateHandler(w http.ResponseWriter, req bunrouter.Request) error {
@@ -124,7 +272,7 @@ func (a *API) updateHandler(w http.ResponseWriter, req bunrouter.Request) error
 		})
 	}
 
-	subdomain, err := extractSubdomain(updateReq.Name)
+	subdomain, err := parseNameOfKind(updateReq.Name, kindUser)
 	if err != nil {
 		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
 			Ok:          false,
@@ -134,7 +282,58 @@ func (a *API) updateHandler(w http.ResponseWriter, req bunrouter.Request) error
 
 	normalizedName := subdomain + domainSuffix
 
-	if err := a.store.UpdateName(req.Context(), normalizedName, updateReq.Address); err != nil {
+	var currentName string
+	if updateReq.Current != "" {
+		current, err := parseNameOfKind(updateReq.Current, kindUser)
+		if err != nil {
+			return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+				Ok:          false,
+				Description: err.Error(),
+			})
+		}
+		currentName = current + domainSuffix
+	}
+
+	if rejected, err := a.rejectByPolicy(w, subdomain); rejected {
+		return err
+	}
+
+	if err := a.store.UpdateName(req.Context(), currentName, normalizedName, updateReq.Address); err != nil {
+		if errors.Is(err, store.ErrNameReserved) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Name is reserved",
+			})
+		}
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Name not found",
+			})
+		}
+
+		if errors.Is(err, store.ErrNotOwner) {
+			return httputil.JSON(w, http.StatusForbidden, ErrResponse{
+				Ok:          false,
+				Description: "Name is not owned by this address",
+			})
+		}
+
+		if errors.Is(err, store.ErrRenameCooldown) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "A name of this owner was renamed recently, try again later",
+			})
+		}
+
+		if isUniqueViolation(err) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Name already taken",
+			})
+		}
+
 		a.logg.Error("update failed", "error", err)
 		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
 			Ok:          false,
@@ -163,7 +362,7 @@ func (a *API) upsertHandler(w http.ResponseWriter, req bunrouter.Request) error
 		})
 	}
 
-	subdomain, err := extractSubdomain(upsertReq.Name)
+	subdomain, err := parseNameOfKind(upsertReq.Name, kindUser)
 	if err != nil {
 		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
 			Ok:          false,
@@ -173,7 +372,39 @@ func (a *API) upsertHandler(w http.ResponseWriter, req bunrouter.Request) error
 
 	normalizedName := subdomain + domainSuffix
 
-	if err := a.store.UpsertName(req.Context(), normalizedName, upsertReq.Address); err != nil {
+	if rejected, err := a.rejectByPolicy(w, subdomain); rejected {
+		return err
+	}
+
+	if err := a.store.UpsertName(req.Context(), normalizedName, upsertReq.Address, tenantFromContext(req.Context())); err != nil {
+		if errors.Is(err, store.ErrNameReserved) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Name is reserved",
+			})
+		}
+
+		if errors.Is(err, store.ErrNotOwner) {
+			return httputil.JSON(w, http.StatusForbidden, ErrResponse{
+				Ok:          false,
+				Description: "Address does not own its current name",
+			})
+		}
+
+		if errors.Is(err, store.ErrRenameCooldown) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "A name of this owner was renamed recently, try again later",
+			})
+		}
+
+		if isUniqueViolation(err) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Name already taken",
+			})
+		}
+
 		a.logg.Error("upsert failed", "error", err)
 		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
 			Ok:          false,
@@ -191,20 +422,11 @@ func (a *API) upsertHandler(w http.ResponseWriter, req bunrouter.Request) error
 	})
 }
 
-func extractSubdomain(hint string) (string, error) {
-	hint = strings.TrimSuffix(hint, domainSuffix)
-
-	parts := strings.Split(hint, ".")
-	if len(parts) > 1 {
-		return "", fmt.Errorf("invalid ENS name")
-	}
-	subdomain := strings.ToLower(parts[0])
-	if !isValidSubdomain(subdomain) {
-		return "", fmt.Errorf("invalid subdomain format: only letters and numbers are allowed")
-	}
-	return subdomain, nil
-}
-
 func isValidSubdomain(subdomain string) bool {
 	return validSubdomain.MatchString(subdomain)
 }
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -1,6 +1,20 @@
 package api
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/namegen"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/policy"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/uptrace/bunrouter"
+)
 
 func TestIsValidSubdomain(t *testing.T) {
 	tests := []struct {
@@ -24,3 +38,185 @@ func TestIsValidSubdomain(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterAutoChosenBeyondFirstRounds(t *testing.T) {
+	f := newFakeStore()
+	a := newTestAPI(f)
+	a.policy = policy.Default()
+	strategies, err := namegen.NewChain(defaultAutoChooseStrategies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.autoChooseStrategies = strategies
+
+	// Take every candidate of the first rounds, which used to be all autoChoose ever tried.
+	for round := range 5 {
+		candidates, _ := a.autoChooseCandidates("mama", round)
+		for _, name := range candidates {
+			f.names[name] = &store.NameRecord{Name: name, Kind: kindUser}
+		}
+	}
+
+	name, err := a.registerAutoChosen(t.Context(), "mama", store.NewName{
+		Address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+	})
+	if err != nil {
+		t.Fatalf("registerAutoChosen() error: %v", err)
+	}
+	if f.names[name].Address != "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed" {
+		t.Errorf("registered %q for another address", name)
+	}
+}
+
+// racingStore misses every name on lookup, as if a concurrent registration landed right after it.
+type racingStore struct {
+	*fakeStore
+}
+
+func (racingStore) LookupName(context.Context, string) (string, error) {
+	return "", pgx.ErrNoRows
+}
+
+func TestRegisterLostRaceFallsBackToAutoChoose(t *testing.T) {
+	f := newFakeStore()
+	f.names["mama.sarafu.eth"] = &store.NameRecord{Name: "mama.sarafu.eth", Kind: kindUser}
+
+	a := newTestAPI(racingStore{f})
+	a.policy = policy.Default()
+	strategies, err := namegen.NewChain(defaultAutoChooseStrategies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.autoChooseStrategies = strategies
+
+	body := `{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","hint":"mama.sarafu.eth"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	if err := a.registerHandler(rec, bunrouter.NewRequest(req)); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Result struct {
+			Name       string `json:"name"`
+			AutoChoose bool   `json:"autoChoose"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Result.AutoChoose || resp.Result.Name == "mama.sarafu.eth" {
+		t.Errorf("result = %+v, want an autochosen alternative", resp.Result)
+	}
+}
+
+func TestUpdateByOwner(t *testing.T) {
+	const (
+		owner = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+		vault = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+	)
+
+	update := func(t *testing.T, body string) (int, *fakeStore) {
+		f := newFakeStore()
+		// The name is controlled by owner but resolves to the vault.
+		f.names["peter.sarafu.eth"] = &store.NameRecord{Name: "peter.sarafu.eth", Address: vault, Owner: owner, Kind: kindUser}
+
+		a := newTestAPI(f)
+		a.policy = policy.Default()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		if err := a.updateHandler(rec, bunrouter.NewRequest(req)); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, f
+	}
+
+	t.Run("owner", func(t *testing.T) {
+		code, f := update(t, `{"current":"peter.sarafu.eth","name":"peterk.sarafu.eth","address":"`+owner+`"}`)
+		if code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", code, http.StatusOK)
+		}
+		if record := f.names["peterk.sarafu.eth"]; record == nil || record.Address != vault {
+			t.Errorf("renamed record = %+v, want it to keep resolving to the vault", record)
+		}
+	})
+
+	t.Run("resolved address", func(t *testing.T) {
+		code, f := update(t, `{"current":"peter.sarafu.eth","name":"peterk.sarafu.eth","address":"`+vault+`"}`)
+		if code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", code, http.StatusForbidden)
+		}
+		if f.names["peter.sarafu.eth"] == nil {
+			t.Error("name renamed by an address that does not own it")
+		}
+	})
+
+	t.Run("primary name of the resolved address", func(t *testing.T) {
+		if code, _ := update(t, `{"name":"peterk.sarafu.eth","address":"`+vault+`"}`); code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", code, http.StatusForbidden)
+		}
+	})
+}
+
+func TestRenameGracePeriodAndCooldown(t *testing.T) {
+	const owner = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	f := newFakeStore()
+	f.renameGracePeriod = time.Hour
+	f.renameCooldown = time.Hour
+	f.names["peter.sarafu.eth"] = &store.NameRecord{Name: "peter.sarafu.eth", Address: owner, Owner: owner, Kind: kindUser}
+	f.names["petershop.sarafu.eth"] = &store.NameRecord{Name: "petershop.sarafu.eth", Address: owner, Owner: owner, Kind: kindUser}
+
+	a := newTestAPI(f)
+	a.policy = policy.Default()
+
+	router := bunrouter.New()
+	router.PUT("/update", a.updateHandler)
+	router.GET("/resolve/:name", a.resolveHandler)
+
+	update := func(current, name string) int {
+		req := httptest.NewRequest(http.MethodPut, "/update", strings.NewReader(`{"current":"`+current+`","name":"`+name+`","address":"`+owner+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := update("peter.sarafu.eth", "peterk.sarafu.eth"); code != http.StatusOK {
+		t.Fatalf("rename status = %d, want %d", code, http.StatusOK)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resolve/peter.sarafu.eth", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("old name status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var resp struct {
+		Result struct {
+			Name    string `json:"name"`
+			Address string `json:"address"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Name != "peterk.sarafu.eth" || resp.Result.Address != owner {
+		t.Errorf("old name resolved to %+v, want the renamed name", resp.Result)
+	}
+
+	// The cooldown applies to every name of the owner, not just the renamed one.
+	if code := update("petershop.sarafu.eth", "petersshop.sarafu.eth"); code != http.StatusConflict {
+		t.Errorf("rename of another name within cooldown status = %d, want %d", code, http.StatusConflict)
+	}
+
+	f.renamedAt[owner] = time.Now().Add(-2 * time.Hour)
+	if code := update("peterk.sarafu.eth", "peter.sarafu.eth"); code != http.StatusOK {
+		t.Errorf("rename back within the grace period status = %d, want %d", code, http.StatusOK)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const defaultReservationTTL = 15 * time.Minute
+
+// reserveHandler holds a name for a short TTL so that onboarding can pick it before the wallet address
+// exists. The returned token is later passed to register as reservationToken.
+func (a *API) reserveHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var reserveReq ReserveRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &reserveReq); err != nil {
+		a.logg.Error("validation failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	kind := reserveReq.Kind
+	if kind == "" {
+		kind = kindUser
+	}
+
+	subdomain, err := parseNameOfKind(reserveReq.Name, kind)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+	normalizedName := fullName(subdomain, kind)
+
+	if rejected, err := a.rejectByPolicy(w, subdomain); rejected {
+		return err
+	}
+
+	ttl := a.reservationTTL
+	if reserveReq.TTL > 0 {
+		ttl = time.Duration(reserveReq.TTL) * time.Second
+	}
+
+	token, err := generateNonce()
+	if err != nil {
+		a.logg.Error("token generation failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	expiresAt, err := a.store.ReserveName(req.Context(), normalizedName, token, principalFromContext(req.Context()).Subject, ttl)
+	if err != nil {
+		if errors.Is(err, store.ErrNameUnavailable) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Name is already registered or reserved",
+			})
+		}
+
+		a.logg.Error("reserve failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name reserved",
+		Result: map[string]any{
+			"name":             normalizedName,
+			"reservationToken": token,
+			"expiresAt":        expiresAt,
+		},
+	})
+}
+
+func (a *API) releaseReservationHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	released, err := a.store.ReleaseReservation(req.Context(), req.Param("token"))
+	if err != nil {
+		a.logg.Error("release reservation failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	if !released {
+		return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+			Ok:          false,
+			Description: "Reservation not found",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Reservation released",
+		Result:      map[string]any{},
+	})
+}
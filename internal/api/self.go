@@ -0,0 +1,399 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/grassrootseconomics/ens-offchain-resolver/pkg/siwe"
+	"github.com/jackc/pgx/v5"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const challengeTTL = 10 * time.Minute
+
+// selfChallengeHandler records the requested change against a fresh nonce and returns the SIWE message
+// the owner of the name, or the recipient of a transfer, has to sign to apply it.
+func (a *API) selfChallengeHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var challengeReq SelfChallengeRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &challengeReq); err != nil {
+		a.logg.Error("validation failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	hasChanges := challengeReq.NewName != "" || challengeReq.Address != "" || len(challengeReq.Texts) > 0
+	if !hasChanges && challengeReq.AcceptTransfer == 0 {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Nothing to update",
+		})
+	}
+	if hasChanges && challengeReq.AcceptTransfer != 0 {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Accepting a transfer cannot be combined with other changes",
+		})
+	}
+
+	subdomain, kind, err := parseName(challengeReq.Name, kindUser)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+	normalizedName := fullName(subdomain, kind)
+
+	if err := validateTexts(kind, challengeReq.Texts, true); err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+
+	action := SelfAction{
+		Address:        challengeReq.Address,
+		Texts:          challengeReq.Texts,
+		AcceptTransfer: challengeReq.AcceptTransfer,
+	}
+	if challengeReq.NewName != "" {
+		newSubdomain, err := parseNameOfKind(challengeReq.NewName, kind)
+		if err != nil {
+			return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+				Ok:          false,
+				Description: err.Error(),
+			})
+		}
+		if rejected, err := a.rejectByPolicy(w, newSubdomain); rejected {
+			return err
+		}
+		action.NewName = fullName(newSubdomain, kind)
+	}
+
+	record, err := a.store.LookupNameRecord(req.Context(), normalizedName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Name not found",
+			})
+		}
+
+		a.logg.Error("lookup failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	// Old names in their rename grace period resolve to the current one.
+	normalizedName = record.Name
+
+	signer, description := record.Owner, "Sign the message with the owner of the name"
+	if action.AcceptTransfer != 0 {
+		transfer, err := a.store.LookupTransfer(req.Context(), action.AcceptTransfer)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			a.logg.Error("lookup transfer failed", "error", err)
+			return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+				Ok:          false,
+				Description: "Internal server error",
+			})
+		}
+		if err != nil || transfer.Status != transferPending || transfer.Name != normalizedName {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "No pending transfer of this name",
+			})
+		}
+		signer, description = transfer.ToAddress, "Sign the message with the address receiving the name"
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		a.logg.Error("nonce generation failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	encodedAction, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	expiresAt := now.Add(challengeTTL)
+	signerAddress := common.HexToAddress(signer)
+	statement := action.statement(normalizedName)
+	if err := a.store.CreateSIWEChallenge(req.Context(), store.SIWEChallenge{
+		Nonce:     nonce,
+		Name:      normalizedName,
+		Signer:    signerAddress.Hex(),
+		Statement: statement,
+		Action:    encodedAction,
+		TTL:       challengeTTL,
+	}); err != nil {
+		a.logg.Error("create challenge failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	message := siwe.Message{
+		Domain:         a.siweDomain,
+		Address:        signerAddress,
+		Statement:      statement,
+		URI:            a.siweURI,
+		Version:        "1",
+		ChainID:        a.siweChainID,
+		Nonce:          nonce,
+		IssuedAt:       now,
+		ExpirationTime: &expiresAt,
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: description,
+		Result: map[string]any{
+			"message":   message.String(),
+			"nonce":     nonce,
+			"expiresAt": expiresAt,
+		},
+	})
+}
+
+// selfConfirmHandler verifies a signed challenge message and applies the stored action.
+func (a *API) selfConfirmHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var confirmReq SelfConfirmRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &confirmReq); err != nil {
+		a.logg.Error("validation failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	message, err := siwe.Parse(confirmReq.Message)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+
+	if message.Domain != a.siweDomain || message.URI != a.siweURI || message.ChainID != a.siweChainID {
+		return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
+			Ok:          false,
+			Description: "SIWE message was not issued for this resolver",
+		})
+	}
+
+	if err := message.ValidAt(time.Now()); err != nil {
+		return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+
+	signature, err := hexutil.Decode(confirmReq.Signature)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid signature encoding",
+		})
+	}
+
+	// Verify before consuming the nonce so that forged confirmations cannot burn a pending challenge.
+	valid, err := a.verifySIWESignature(req, message, confirmReq.Message, signature)
+	if err != nil {
+		a.logg.Error("signature verification failed", "error", err)
+		return httputil.JSON(w, http.StatusBadGateway, ErrResponse{
+			Ok:          false,
+			Description: "Could not verify smart account signature",
+		})
+	}
+	if !valid {
+		return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
+			Ok:          false,
+			Description: "Invalid signature",
+		})
+	}
+
+	// A valid signature only proves who signed, the challenge must have been issued to that signer with this
+	// statement, otherwise anyone knowing the nonce could burn it.
+	name, encodedAction, err := a.store.ConsumeSIWEChallenge(req.Context(), message.Nonce, message.Address.Hex(), message.Statement)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusUnauthorized, ErrResponse{
+				Ok:          false,
+				Description: "Unknown, expired or already used nonce, or not issued to this signer",
+			})
+		}
+
+		a.logg.Error("consume challenge failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	var action SelfAction
+	if err := json.Unmarshal(encodedAction, &action); err != nil {
+		return err
+	}
+
+	record, err := a.store.LookupNameRecord(req.Context(), name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Name not found",
+			})
+		}
+
+		a.logg.Error("lookup failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	name = record.Name
+
+	if action.AcceptTransfer != 0 {
+		return a.confirmTransfer(w, req, action.AcceptTransfer, name, message.Address.Hex())
+	}
+
+	if !strings.EqualFold(record.Owner, message.Address.Hex()) {
+		return httputil.JSON(w, http.StatusForbidden, ErrResponse{
+			Ok:          false,
+			Description: "Signer is not the owner of the name",
+		})
+	}
+
+	if action.NewName != "" {
+		if err := a.store.RenameName(req.Context(), name, action.NewName); err != nil {
+			if isUniqueViolation(err) || errors.Is(err, store.ErrNameReserved) {
+				return httputil.JSON(w, http.StatusConflict, ErrResponse{
+					Ok:          false,
+					Description: "Name already taken",
+				})
+			}
+
+			if errors.Is(err, store.ErrRenameCooldown) {
+				return httputil.JSON(w, http.StatusConflict, ErrResponse{
+					Ok:          false,
+					Description: "A name of this owner was renamed recently, try again later",
+				})
+			}
+
+			a.logg.Error("update failed", "error", err)
+			return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+				Ok:          false,
+				Description: "Internal server error",
+			})
+		}
+		name = action.NewName
+	}
+
+	address := record.Address
+	if action.Address != "" {
+		if err := a.store.SetNameAddress(req.Context(), name, record.Owner, action.Address); err != nil {
+			a.logg.Error("set name address failed", "error", err)
+			return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+				Ok:          false,
+				Description: "Internal server error",
+			})
+		}
+		address = action.Address
+	}
+
+	if len(action.Texts) > 0 {
+		if err := a.store.SetTextRecords(req.Context(), name, action.Texts); err != nil {
+			a.logg.Error("set text records failed", "error", err)
+			return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+				Ok:          false,
+				Description: "Internal server error",
+			})
+		}
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name updated successfully",
+		Result: map[string]any{
+			"name":    name,
+			"owner":   record.Owner,
+			"address": address,
+		},
+	})
+}
+
+// verifySIWESignature accepts EOA signatures and falls back to EIP-1271 for smart accounts.
+func (a *API) verifySIWESignature(req bunrouter.Request, message *siwe.Message, raw string, signature []byte) (bool, error) {
+	if signer, err := siwe.RecoverAddress(raw, signature); err == nil && signer == message.Address {
+		return true, nil
+	}
+
+	return a.ensProvider.IsValidSignature(req.Context(), message.Address, siwe.Hash(raw), signature)
+}
+
+// statement spells out every change including the text record values, the signer approves exactly what is
+// applied. Values are quoted so that the statement stays on a single line.
+func (s SelfAction) statement(name string) string {
+	var changes []string
+	if s.AcceptTransfer != 0 {
+		changes = append(changes, fmt.Sprintf("accept transfer %d of %s to this address", s.AcceptTransfer, name))
+	}
+	if s.NewName != "" {
+		changes = append(changes, fmt.Sprintf("rename %s to %s", name, s.NewName))
+	}
+	if s.Address != "" {
+		changes = append(changes, fmt.Sprintf("point %s to %s", name, s.Address))
+	}
+	var set, removed []string
+	for _, key := range slices.Sorted(maps.Keys(s.Texts)) {
+		if s.Texts[key] == "" {
+			removed = append(removed, strconv.Quote(key))
+			continue
+		}
+		set = append(set, strconv.Quote(key)+"="+strconv.Quote(s.Texts[key]))
+	}
+	if len(set) > 0 {
+		changes = append(changes, fmt.Sprintf("set the text records %s of %s", strings.Join(set, ", "), name))
+	}
+	if len(removed) > 0 {
+		changes = append(changes, fmt.Sprintf("remove the text records %s of %s", strings.Join(removed, ", "), name))
+	}
+
+	return "Allow the Sarafu resolver to " + strings.Join(changes, " and ") + "."
+}
+
+// generateNonce returns a random alphanumeric nonce as required by EIP-4361.
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/grassrootseconomics/ens-offchain-resolver/pkg/siwe"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestSelfConfirm(t *testing.T) {
+	owner, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attacker, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerAddress := crypto.PubkeyToAddress(owner.PublicKey).Hex()
+
+	setup := func(t *testing.T) (*API, *fakeStore) {
+		f := newFakeStore()
+		f.names["alice.sarafu.eth"] = &store.NameRecord{
+			Name:    "alice.sarafu.eth",
+			Address: ownerAddress,
+			Owner:   ownerAddress,
+			Kind:    kindUser,
+		}
+
+		a := newTestAPI(f)
+		a.siweDomain = "sarafu.network"
+		a.siweURI = "https://sarafu.network"
+		a.siweChainID = 1
+		return a, f
+	}
+
+	call := func(t *testing.T, handler bunrouter.HandlerFunc, body any) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(b)))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		if err := handler(rec, bunrouter.NewRequest(req)); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	challenge := func(t *testing.T, a *API) *siwe.Message {
+		rec := call(t, a.selfChallengeHandler, map[string]any{
+			"name":  "alice.sarafu.eth",
+			"texts": map[string]string{"url": "https://example.com"},
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("challenge status = %d, body %s", rec.Code, rec.Body)
+		}
+
+		var resp struct {
+			Result struct {
+				Message string `json:"message"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		message, err := siwe.Parse(resp.Result.Message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+
+	confirm := func(t *testing.T, a *API, message *siwe.Message, key *ecdsa.PrivateKey) int {
+		raw := message.String()
+		sig, err := crypto.Sign(siwe.Hash(raw).Bytes(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return call(t, a.selfConfirmHandler, SelfConfirmRequest{
+			Message:   raw,
+			Signature: hexutil.Encode(sig),
+		}).Code
+	}
+
+	t.Run("owner", func(t *testing.T) {
+		a, f := setup(t)
+		message := challenge(t, a)
+		if !strings.Contains(message.Statement, `"url"="https://example.com"`) {
+			t.Errorf("statement %q does not show the value to be set", message.Statement)
+		}
+		if code := confirm(t, a, message, owner); code != http.StatusOK {
+			t.Fatalf("confirm status = %d, want %d", code, http.StatusOK)
+		}
+		if got := f.texts["alice.sarafu.eth"]["url"]; got != "https://example.com" {
+			t.Errorf("url = %q, want the signed change applied", got)
+		}
+	})
+
+	t.Run("wrong signer", func(t *testing.T) {
+		a, f := setup(t)
+		message := challenge(t, a)
+
+		forged := *message
+		forged.Address = crypto.PubkeyToAddress(attacker.PublicKey)
+		if code := confirm(t, a, &forged, attacker); code != http.StatusUnauthorized {
+			t.Errorf("confirm by another signer status = %d, want %d", code, http.StatusUnauthorized)
+		}
+		if len(f.texts["alice.sarafu.eth"]) != 0 {
+			t.Error("change applied for another signer")
+		}
+
+		// The challenge must survive the attempt.
+		if code := confirm(t, a, message, owner); code != http.StatusOK {
+			t.Errorf("confirm by the owner after a forged attempt status = %d, want %d", code, http.StatusOK)
+		}
+	})
+
+	t.Run("changed statement", func(t *testing.T) {
+		a, _ := setup(t)
+		message := challenge(t, a)
+
+		message.Statement = "Allow the Sarafu resolver to rename alice.sarafu.eth to mallory.sarafu.eth."
+		if code := confirm(t, a, message, owner); code != http.StatusUnauthorized {
+			t.Errorf("confirm with another statement status = %d, want %d", code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		a, _ := setup(t)
+		message := challenge(t, a)
+
+		if code := confirm(t, a, message, owner); code != http.StatusOK {
+			t.Fatalf("first confirm status = %d, want %d", code, http.StatusOK)
+		}
+		if code := confirm(t, a, message, owner); code != http.StatusUnauthorized {
+			t.Errorf("replayed confirm status = %d, want %d", code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("expired nonce", func(t *testing.T) {
+		a, f := setup(t)
+		message := challenge(t, a)
+
+		f.challenges[message.Nonce].expiresAt = time.Now().Add(-time.Second)
+		if code := confirm(t, a, message, owner); code != http.StatusUnauthorized {
+			t.Errorf("confirm of an expired nonce status = %d, want %d", code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("expired message", func(t *testing.T) {
+		a, f := setup(t)
+		message := challenge(t, a)
+
+		expired := time.Now().Add(-time.Second).UTC().Truncate(time.Second)
+		message.ExpirationTime = &expired
+		if code := confirm(t, a, message, owner); code != http.StatusUnauthorized {
+			t.Errorf("confirm of an expired message status = %d, want %d", code, http.StatusUnauthorized)
+		}
+		if f.challenges[message.Nonce].used {
+			t.Error("expired message consumed the challenge")
+		}
+	})
+}
+
+func TestSelfActionStatement(t *testing.T) {
+	action := SelfAction{Texts: map[string]string{
+		"description": "line one\nline two",
+		"url":         "",
+	}}
+
+	got := action.statement("alice.sarafu.eth")
+	want := `Allow the Sarafu resolver to set the text records "description"="line one\nline two" of alice.sarafu.eth` +
+		` and remove the text records "url" of alice.sarafu.eth.`
+	if got != want {
+		t.Errorf("statement() = %q, want %q", got, want)
+	}
+	if strings.Contains(got, "\n") {
+		t.Error("statement spans several lines")
+	}
+}
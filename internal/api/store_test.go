@@ -0,0 +1,453 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/kamikazechaser/common/httputil"
+)
+
+// fakeStore keeps just enough state in memory for handler tests, calling a method it does not implement panics.
+type fakeStore struct {
+	store.Store
+
+	mu         sync.Mutex
+	names      map[string]*store.NameRecord
+	texts      map[string]map[string]string
+	challenges map[string]*fakeChallenge
+	apiKeys    map[string]*store.APIKey
+	idempotent map[string]*fakeIdempotencyKey
+	redirects  map[string]*fakeRedirect
+	transfers  map[int]*store.Transfer
+	renamedAt  map[string]time.Time
+
+	renameGracePeriod time.Duration
+	renameCooldown    time.Duration
+	expiryGracePeriod time.Duration
+	leases            map[string]time.Duration
+}
+
+type fakeChallenge struct {
+	store.SIWEChallenge
+	expiresAt time.Time
+	used      bool
+}
+
+type fakeRedirect struct {
+	record    *store.NameRecord
+	expiresAt time.Time
+}
+
+type fakeIdempotencyKey struct {
+	store.IdempotencyRecord
+	createdAt time.Time
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		names:      make(map[string]*store.NameRecord),
+		texts:      make(map[string]map[string]string),
+		challenges: make(map[string]*fakeChallenge),
+		apiKeys:    make(map[string]*store.APIKey),
+		idempotent: make(map[string]*fakeIdempotencyKey),
+		redirects:  make(map[string]*fakeRedirect),
+		transfers:  make(map[int]*store.Transfer),
+		renamedAt:  make(map[string]time.Time),
+	}
+}
+
+func newTestAPI(s store.Store) *API {
+	return &API{
+		validator: httputil.NewValidator(""),
+		logg:      slog.New(slog.DiscardHandler),
+		store:     s,
+	}
+}
+
+// lookup finds an active name, or the name an old name in its rename grace period moved to. Names past their
+// expiry grace period are released.
+func (f *fakeStore) lookup(name string) (*store.NameRecord, bool) {
+	record, ok := f.names[name]
+	if !ok {
+		redirect, ok := f.redirects[name]
+		if !ok || !redirect.expiresAt.After(time.Now()) {
+			return nil, false
+		}
+		record = redirect.record
+	}
+	if record.ExpiresAt != nil && !record.ExpiresAt.Add(f.expiryGracePeriod).After(time.Now()) {
+		return nil, false
+	}
+	return record, true
+}
+
+func (f *fakeStore) LookupNameRecord(_ context.Context, name string) (*store.NameRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record, ok := f.lookup(name)
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *record
+	copied.Expired = record.ExpiresAt != nil && !record.ExpiresAt.After(time.Now())
+	return &copied, nil
+}
+
+func (f *fakeStore) RenewName(_ context.Context, name string, kind string) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record, ok := f.lookup(name)
+	if !ok || record.ExpiresAt == nil || f.leases[kind] <= 0 {
+		return time.Time{}, store.ErrNoLease
+	}
+
+	from := time.Now()
+	if record.ExpiresAt.After(from) {
+		from = *record.ExpiresAt
+	}
+	expiresAt := from.Add(f.leases[kind])
+	record.ExpiresAt = &expiresAt
+	return expiresAt, nil
+}
+
+func (f *fakeStore) BatchLookupNames(_ context.Context, names []string) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	addresses := make(map[string]string)
+	for _, name := range names {
+		if record, ok := f.lookup(name); ok {
+			addresses[name] = record.Address
+		}
+	}
+	return addresses, nil
+}
+
+func (f *fakeStore) BatchReverseLookup(_ context.Context, addresses []string) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	names := make(map[string]string)
+	for _, record := range f.names {
+		if record.Primary && slices.Contains(addresses, record.Address) {
+			names[record.Address] = record.Name
+		}
+	}
+	return names, nil
+}
+
+func (f *fakeStore) ReverseLookup(_ context.Context, address string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, record := range f.names {
+		if record.Primary && record.Address == address {
+			return record.Name, nil
+		}
+	}
+	return "", pgx.ErrNoRows
+}
+
+func (f *fakeStore) LookupTextRecords(_ context.Context, name string) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return maps.Clone(f.texts[name]), nil
+}
+
+func (f *fakeStore) SetTextRecords(_ context.Context, name string, texts map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.texts[name] == nil {
+		f.texts[name] = make(map[string]string)
+	}
+	maps.Copy(f.texts[name], texts)
+	return nil
+}
+
+func (f *fakeStore) CreateSIWEChallenge(_ context.Context, challenge store.SIWEChallenge) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.challenges[challenge.Nonce] = &fakeChallenge{
+		SIWEChallenge: challenge,
+		expiresAt:     time.Now().Add(challenge.TTL),
+	}
+	return nil
+}
+
+func (f *fakeStore) ConsumeSIWEChallenge(_ context.Context, nonce string, signer string, statement string) (string, []byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.challenges[nonce]
+	if !ok || c.used || c.Signer != signer || c.Statement != statement || !c.expiresAt.After(time.Now()) {
+		return "", nil, pgx.ErrNoRows
+	}
+	c.used = true
+	return c.Name, c.Action, nil
+}
+
+func (f *fakeStore) RegisterName(_ context.Context, name store.NewName) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.lookup(name.Name); ok {
+		return &pgconn.PgError{Code: "23505"}
+	}
+	f.names[name.Name] = &store.NameRecord{
+		Name:    name.Name,
+		Address: name.Address,
+		Owner:   name.Address,
+		Kind:    name.Kind,
+	}
+	if len(name.Texts) > 0 {
+		f.texts[name.Name] = maps.Clone(name.Texts)
+	}
+	return nil
+}
+
+func (f *fakeStore) ListAddressNames(_ context.Context, address string, kind string) ([]store.AddressName, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var names []store.AddressName
+	for _, record := range f.names {
+		if record.Address == address && (kind == "" || record.Kind == kind) {
+			names = append(names, store.AddressName{
+				Name:  record.Name,
+				Owner: record.Owner,
+				Kind:  record.Kind,
+			})
+		}
+	}
+	return names, nil
+}
+
+func (f *fakeStore) UseAPIKey(_ context.Context, keyHash string) (*store.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	apiKey, ok := f.apiKeys[keyHash]
+	if !ok || !apiKey.Active || (apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(time.Now())) {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *apiKey
+	return &copied, nil
+}
+
+func (f *fakeStore) LookupName(_ context.Context, name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record, ok := f.lookup(name)
+	if !ok {
+		return "", pgx.ErrNoRows
+	}
+	return record.Address, nil
+}
+
+func (f *fakeStore) RegisterFirstAvailable(_ context.Context, candidates []string, name store.NewName) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, candidate := range candidates {
+		if _, ok := f.lookup(candidate); ok {
+			continue
+		}
+		f.names[candidate] = &store.NameRecord{
+			Name:    candidate,
+			Address: name.Address,
+			Owner:   name.Address,
+			Kind:    kindUser,
+		}
+		if len(name.Texts) > 0 {
+			f.texts[candidate] = maps.Clone(name.Texts)
+		}
+		return candidate, nil
+	}
+	return "", pgx.ErrNoRows
+}
+
+func (f *fakeStore) ClaimIdempotencyKey(_ context.Context, subject string, key string, requestHash string, window time.Duration, lease time.Duration) (*store.IdempotencyRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	windowStart, leaseStart := now.Add(-window), now.Add(-lease)
+	existing, ok := f.idempotent[subject+"/"+key]
+	if ok && !existing.createdAt.Before(windowStart) && (existing.StatusCode != 0 || !existing.createdAt.Before(leaseStart)) {
+		record := existing.IdempotencyRecord
+		return &record, nil
+	}
+
+	f.idempotent[subject+"/"+key] = &fakeIdempotencyKey{
+		IdempotencyRecord: store.IdempotencyRecord{RequestHash: requestHash},
+		createdAt:         now,
+	}
+	return nil, nil
+}
+
+func (f *fakeStore) CompleteIdempotencyKey(_ context.Context, subject string, key string, statusCode int, response []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if existing, ok := f.idempotent[subject+"/"+key]; ok {
+		existing.StatusCode = statusCode
+		existing.Response = response
+	}
+	return nil
+}
+
+func (f *fakeStore) ReleaseIdempotencyKey(_ context.Context, subject string, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if existing, ok := f.idempotent[subject+"/"+key]; ok && existing.StatusCode == 0 {
+		delete(f.idempotent, subject+"/"+key)
+	}
+	return nil
+}
+
+func (f *fakeStore) UpdateName(_ context.Context, currentName string, primaryName string, owner string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var current *store.NameRecord
+	for _, record := range f.names {
+		if record.Name == currentName || (currentName == "" && record.Address == owner) {
+			current = record
+			break
+		}
+	}
+	if current == nil {
+		return pgx.ErrNoRows
+	}
+	if current.Owner != owner {
+		return store.ErrNotOwner
+	}
+	if current.Name == primaryName {
+		return nil
+	}
+	if taken, ok := f.lookup(primaryName); ok && taken != current {
+		return &pgconn.PgError{Code: "23505"}
+	}
+	if renamedAt, ok := f.renamedAt[owner]; ok && time.Since(renamedAt) < f.renameCooldown {
+		return store.ErrRenameCooldown
+	}
+
+	delete(f.redirects, primaryName)
+	if f.renameGracePeriod > 0 {
+		f.redirects[current.Name] = &fakeRedirect{record: current, expiresAt: time.Now().Add(f.renameGracePeriod)}
+	}
+	f.renamedAt[owner] = time.Now()
+
+	delete(f.names, current.Name)
+	current.Name = primaryName
+	f.names[primaryName] = current
+	return nil
+}
+
+func (f *fakeStore) CreateTransfer(_ context.Context, transfer *store.Transfer, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record, ok := f.lookup(transfer.Name)
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if record.Owner != transfer.FromOwner {
+		return store.ErrNotOwner
+	}
+	for _, pending := range f.transfers {
+		if pending.Name == transfer.Name && pending.Status == transferPending && pending.ExpiresAt.After(time.Now()) {
+			return store.ErrTransferPending
+		}
+	}
+
+	transfer.ID = len(f.transfers) + 1
+	transfer.Status = transferPending
+	transfer.CreatedAt = time.Now()
+	transfer.ExpiresAt = transfer.CreatedAt.Add(ttl)
+	copied := *transfer
+	f.transfers[transfer.ID] = &copied
+	return nil
+}
+
+func (f *fakeStore) LookupTransfer(_ context.Context, id int) (*store.Transfer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	transfer, ok := f.transfers[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *transfer
+	return &copied, nil
+}
+
+func (f *fakeStore) AcceptTransfer(_ context.Context, id int, _ string) (*store.Transfer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	transfer, ok := f.transfers[id]
+	if !ok || transfer.Status != transferPending || !transfer.ExpiresAt.After(time.Now()) {
+		return nil, store.ErrTransferNotFound
+	}
+	record, ok := f.lookup(transfer.Name)
+	if !ok || record.Owner != transfer.FromOwner {
+		return nil, store.ErrNotOwner
+	}
+
+	record.Owner, record.Address = transfer.ToAddress, transfer.ToAddress
+	completedAt := time.Now()
+	transfer.Status, transfer.CompletedAt = "accepted", &completedAt
+	copied := *transfer
+	return &copied, nil
+}
+
+func (f *fakeStore) CancelTransfer(_ context.Context, id int, _ string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	transfer, ok := f.transfers[id]
+	if !ok || transfer.Status != transferPending {
+		return store.ErrTransferNotFound
+	}
+
+	completedAt := time.Now()
+	transfer.Status, transfer.CompletedAt = "cancelled", &completedAt
+	return nil
+}
+
+// staleReverseStore reverse resolves address to name, whatever name resolves to.
+type staleReverseStore struct {
+	*fakeStore
+	address string
+	name    string
+}
+
+func (s staleReverseStore) ReverseLookup(ctx context.Context, address string) (string, error) {
+	if address == s.address {
+		return s.name, nil
+	}
+	return s.fakeStore.ReverseLookup(ctx, address)
+}
+
+func (s staleReverseStore) BatchReverseLookup(ctx context.Context, addresses []string) (map[string]string, error) {
+	names, err := s.fakeStore.BatchReverseLookup(ctx, addresses)
+	if slices.Contains(addresses, s.address) {
+		names[s.address] = s.name
+	}
+	return names, err
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	defaultTransferTTL = 7 * 24 * time.Hour
+
+	transferPending = "pending"
+)
+
+// createTransferHandler starts moving a name to another address, nothing changes until the recipient
+// accepts through acceptTransferHandler or a signed self-service challenge.
+func (a *API) createTransferHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var transferReq CreateTransferRequest
+
+	if err := a.validator.BindJSONAndValidate(w, req.Request, &transferReq); err != nil {
+		a.logg.Error("validation failed", "error", err)
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Validation failed",
+		})
+	}
+
+	label, kind, err := parseName(transferReq.Name, kindUser)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+
+	transfer := store.Transfer{
+		Name:        fullName(label, kind),
+		FromOwner:   transferReq.From,
+		ToAddress:   transferReq.To,
+		InitiatedBy: principalFromContext(req.Context()).Subject,
+	}
+
+	if err := a.store.CreateTransfer(req.Context(), &transfer, a.transferTTL); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Name not found",
+			})
+		}
+
+		if errors.Is(err, store.ErrNotOwner) {
+			return httputil.JSON(w, http.StatusForbidden, ErrResponse{
+				Ok:          false,
+				Description: "Name is not owned by this owner",
+			})
+		}
+
+		if errors.Is(err, store.ErrTransferPending) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Name already has a pending transfer",
+			})
+		}
+
+		a.logg.Error("create transfer failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Transfer initiated",
+		Result: map[string]any{
+			"transfer": transfer,
+		},
+	})
+}
+
+func (a *API) getTransferHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	id, err := strconv.Atoi(req.Param("id"))
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid transfer id",
+		})
+	}
+
+	transfer, err := a.store.LookupTransfer(req.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Transfer not found",
+			})
+		}
+
+		a.logg.Error("lookup transfer failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Transfer",
+		Result: map[string]any{
+			"transfer": transfer,
+		},
+	})
+}
+
+// acceptTransferHandler accepts a transfer on behalf of the recipient, for services that confirmed the
+// recipient themselves.
+func (a *API) acceptTransferHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	id, err := strconv.Atoi(req.Param("id"))
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid transfer id",
+		})
+	}
+
+	return a.acceptTransfer(w, req, id, principalFromContext(req.Context()).Subject)
+}
+
+// confirmTransfer accepts a transfer signed for through a self-service challenge.
+func (a *API) confirmTransfer(w http.ResponseWriter, req bunrouter.Request, id int, name string, signer string) error {
+	transfer, err := a.store.LookupTransfer(req.Context(), id)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		a.logg.Error("lookup transfer failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+	if err != nil || transfer.Name != name {
+		return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+			Ok:          false,
+			Description: "No pending transfer of this name",
+		})
+	}
+
+	if !strings.EqualFold(transfer.ToAddress, signer) {
+		return httputil.JSON(w, http.StatusForbidden, ErrResponse{
+			Ok:          false,
+			Description: "Signer is not the recipient of the transfer",
+		})
+	}
+
+	return a.acceptTransfer(w, req, id, "siwe:"+signer)
+}
+
+func (a *API) acceptTransfer(w http.ResponseWriter, req bunrouter.Request, id int, actor string) error {
+	transfer, err := a.store.AcceptTransfer(req.Context(), id, actor)
+	if err != nil {
+		if errors.Is(err, store.ErrTransferNotFound) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Transfer not found, expired or already finished",
+			})
+		}
+
+		if errors.Is(err, store.ErrNotOwner) {
+			return httputil.JSON(w, http.StatusConflict, ErrResponse{
+				Ok:          false,
+				Description: "Name changed owner since the transfer was initiated",
+			})
+		}
+
+		a.logg.Error("accept transfer failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Transfer accepted",
+		Result: map[string]any{
+			"transfer": transfer,
+		},
+	})
+}
+
+func (a *API) cancelTransferHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	id, err := strconv.Atoi(req.Param("id"))
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: "Invalid transfer id",
+		})
+	}
+
+	if err := a.store.CancelTransfer(req.Context(), id, principalFromContext(req.Context()).Subject); err != nil {
+		if errors.Is(err, store.ErrTransferNotFound) {
+			return httputil.JSON(w, http.StatusNotFound, ErrResponse{
+				Ok:          false,
+				Description: "Transfer not found or already finished",
+			})
+		}
+
+		a.logg.Error("cancel transfer failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Transfer cancelled",
+		Result: map[string]any{
+			"id": id,
+		},
+	})
+}
+
+func (a *API) nameHistoryHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	label, kind, err := parseName(req.Param("name"), kindUser)
+	if err != nil {
+		return httputil.JSON(w, http.StatusBadRequest, ErrResponse{
+			Ok:          false,
+			Description: err.Error(),
+		})
+	}
+	name := fullName(label, kind)
+
+	events, err := a.store.ListAliasEvents(req.Context(), name)
+	if err != nil {
+		a.logg.Error("list alias events failed", "error", err)
+		return httputil.JSON(w, http.StatusInternalServerError, ErrResponse{
+			Ok:          false,
+			Description: "Internal server error",
+		})
+	}
+
+	return httputil.JSON(w, http.StatusOK, OKResponse{
+		Ok:          true,
+		Description: "Name history",
+		Result: map[string]any{
+			"name":   name,
+			"events": events,
+		},
+	})
+}
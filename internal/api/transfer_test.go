@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestTransferStateMachine(t *testing.T) {
+	const (
+		from  = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+		to    = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+		other = "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB"
+	)
+
+	f := newFakeStore()
+	f.names["alice.sarafu.eth"] = &store.NameRecord{Name: "alice.sarafu.eth", Address: from, Owner: from, Kind: kindUser}
+	a := newTestAPI(f)
+	a.transferTTL = time.Hour
+
+	router := bunrouter.New()
+	router.POST("/transfers", a.createTransferHandler)
+	router.POST("/transfers/:id/accept", a.acceptTransferHandler)
+	router.POST("/transfers/:id/cancel", a.cancelTransferHandler)
+
+	call := func(path string, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), principalKey{}, &principal{Subject: "test"}))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	create := func(owner string) int {
+		return call("/transfers", `{"name":"alice.sarafu.eth","from":"`+owner+`","to":"`+to+`"}`)
+	}
+
+	if code := create(other); code != http.StatusForbidden {
+		t.Errorf("create by another owner status = %d, want %d", code, http.StatusForbidden)
+	}
+
+	if code := create(from); code != http.StatusOK {
+		t.Fatalf("create status = %d, want %d", code, http.StatusOK)
+	}
+	if code := create(from); code != http.StatusConflict {
+		t.Errorf("second pending transfer status = %d, want %d", code, http.StatusConflict)
+	}
+	if record := f.names["alice.sarafu.eth"]; record.Owner != from || record.Address != from {
+		t.Errorf("name changed before the transfer was accepted: %+v", record)
+	}
+
+	if code := call("/transfers/1/cancel", ""); code != http.StatusOK {
+		t.Fatalf("cancel status = %d, want %d", code, http.StatusOK)
+	}
+	if code := call("/transfers/1/accept", ""); code != http.StatusNotFound {
+		t.Errorf("accept of a cancelled transfer status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	if code := create(from); code != http.StatusOK {
+		t.Fatalf("create after cancel status = %d, want %d", code, http.StatusOK)
+	}
+	f.transfers[2].ExpiresAt = time.Now().Add(-time.Second)
+	if code := call("/transfers/2/accept", ""); code != http.StatusNotFound {
+		t.Errorf("accept of an expired transfer status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	if code := create(from); code != http.StatusOK {
+		t.Fatalf("create after expiry status = %d, want %d", code, http.StatusOK)
+	}
+	if code := call("/transfers/3/accept", ""); code != http.StatusOK {
+		t.Fatalf("accept status = %d, want %d", code, http.StatusOK)
+	}
+	if record := f.names["alice.sarafu.eth"]; record.Owner != to || record.Address != to {
+		t.Errorf("accepted name = %+v, want it owned by and resolving to the recipient", record)
+	}
+	if code := call("/transfers/3/cancel", ""); code != http.StatusNotFound {
+		t.Errorf("cancel of an accepted transfer status = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestConfirmTransferRequiresRecipient(t *testing.T) {
+	const (
+		from = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+		to   = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+	)
+
+	f := newFakeStore()
+	f.names["alice.sarafu.eth"] = &store.NameRecord{Name: "alice.sarafu.eth", Address: from, Owner: from, Kind: kindUser}
+	transfer := store.Transfer{Name: "alice.sarafu.eth", FromOwner: from, ToAddress: to}
+	if err := f.CreateTransfer(context.Background(), &transfer, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAPI(f)
+
+	confirm := func(name string, signer string) int {
+		rec := httptest.NewRecorder()
+		req := bunrouter.NewRequest(httptest.NewRequest(http.MethodPost, "/", nil))
+		if err := a.confirmTransfer(rec, req, transfer.ID, name, signer); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code
+	}
+
+	if code := confirm("alice.sarafu.eth", from); code != http.StatusForbidden {
+		t.Errorf("confirm by the sender status = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := confirm("bob.sarafu.eth", to); code != http.StatusNotFound {
+		t.Errorf("confirm for another name status = %d, want %d", code, http.StatusNotFound)
+	}
+	if f.transfers[transfer.ID].Status != transferPending {
+		t.Fatalf("transfer status = %q after rejected confirmations, want pending", f.transfers[transfer.ID].Status)
+	}
+
+	if code := confirm("alice.sarafu.eth", strings.ToLower(to)); code != http.StatusOK {
+		t.Errorf("confirm by the recipient status = %d, want %d", code, http.StatusOK)
+	}
+	if record := f.names["alice.sarafu.eth"]; record.Owner != to {
+		t.Errorf("owner = %s, want the recipient", record.Owner)
+	}
+}
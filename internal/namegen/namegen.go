@@ -0,0 +1,217 @@
+// Package namegen proposes alternative labels when a requested name is already taken.
+//
+// All strategies are deterministic for a given base label and round, so that a retried request walks the
+// same candidates in the same order.
+package namegen
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Strategy returns up to n candidate labels for base. Each round should propose labels not proposed in
+	// earlier rounds, an empty result means the strategy is exhausted.
+	Strategy interface {
+		Name() string
+		Candidates(base string, round int, n int) []string
+	}
+
+	sequential struct{}
+	random     struct{}
+	wordlist   struct {
+		words []string
+	}
+	mutation struct{}
+)
+
+const (
+	maxLabelLength = 63
+	// Random suffixes go up to 999999, far more than a single base ever needs.
+	maxRandomDigits = 6
+)
+
+var (
+	validLabel = regexp.MustCompile(`^[a-z][a-z0-9-]*[a-z0-9]$|^[a-z]$`)
+
+	// Short words that read naturally next to a name in both English and Swahili speaking communities.
+	defaultWords = []string{
+		"amani", "baraka", "neema", "furaha", "tumaini", "upendo", "jua", "shamba",
+		"duka", "kazi", "pamoja", "bora", "shop", "farm", "market", "coop",
+	}
+
+	vowels = []byte("aeiou")
+)
+
+// New returns the strategy registered under name.
+func New(name string) (Strategy, error) {
+	switch name {
+	case "sequential":
+		return sequential{}, nil
+	case "random":
+		return random{}, nil
+	case "wordlist":
+		return wordlist{words: defaultWords}, nil
+	case "mutation":
+		return mutation{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown autochoose strategy %q", name)
+}
+
+// NewChain resolves a list of strategy names, preserving their order of preference.
+func NewChain(names []string) ([]Strategy, error) {
+	strategies := make([]Strategy, 0, len(names))
+	for _, name := range names {
+		s, err := New(name)
+		if err != nil {
+			return nil, err
+		}
+		strategies = append(strategies, s)
+	}
+
+	return strategies, nil
+}
+
+// Generate merges the candidates of all strategies for a round in order of preference, dropping duplicates,
+// invalid labels and the base itself.
+func Generate(strategies []Strategy, base string, round int, n int) []string {
+	seen := map[string]bool{base: true}
+	var candidates []string
+
+	for _, s := range strategies {
+		for _, c := range s.Candidates(base, round, n) {
+			if seen[c] || len(c) > maxLabelLength || !validLabel.MatchString(c) {
+				continue
+			}
+			seen[c] = true
+			candidates = append(candidates, c)
+		}
+	}
+
+	return candidates
+}
+
+func (sequential) Name() string { return "sequential" }
+
+// Candidates returns base1, base2, ... continuing where the previous round stopped.
+func (sequential) Candidates(base string, round int, n int) []string {
+	candidates := make([]string, 0, n)
+	for i := round*n + 1; i <= (round+1)*n; i++ {
+		candidates = append(candidates, base+strconv.Itoa(i))
+	}
+	return candidates
+}
+
+func (random) Name() string { return "random" }
+
+// Candidates pages through a shuffle of the two digit suffixes (10-99), then of the three digit ones
+// (100-999) and so on, seeded by the base so that the draw is reproducible. Every suffix of a width is
+// proposed before a digit is added.
+func (random) Candidates(base string, round int, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	low := 10
+	for digits := 2; digits <= maxRandomDigits; digits++ {
+		span := low*10 - low
+		pages := (span + n - 1) / n
+		if round >= pages {
+			round -= pages
+			low *= 10
+			continue
+		}
+
+		h := fnv.New64a()
+		h.Write([]byte(base))
+		rng := rand.New(rand.NewPCG(h.Sum64(), uint64(digits)))
+
+		// i -> (a*i + b) mod span is a permutation when a and span are coprime, without materializing it.
+		a := rng.IntN(span-1) + 1
+		for gcd(a, span) != 1 {
+			a = rng.IntN(span-1) + 1
+		}
+		b := rng.IntN(span)
+
+		start := round * n
+		candidates := make([]string, 0, min(n, span-start))
+		for i := start; i < min(start+n, span); i++ {
+			candidates = append(candidates, base+strconv.Itoa(low+(a*i+b)%span))
+		}
+		return candidates
+	}
+
+	return nil
+}
+
+func (wordlist) Name() string { return "wordlist" }
+
+// Candidates pairs the base with words from the list, first as a suffix then as a prefix.
+func (w wordlist) Candidates(base string, round int, n int) []string {
+	var all []string
+	for _, word := range w.words {
+		all = append(all, base+"-"+word)
+	}
+	for _, word := range w.words {
+		all = append(all, word+"-"+base)
+	}
+
+	return page(all, round, n)
+}
+
+func (mutation) Name() string { return "mutation" }
+
+// Candidates returns spelling variants: a trailing vowel, a doubled inner consonant or a swapped vowel.
+func (mutation) Candidates(base string, round int, n int) []string {
+	var all []string
+	for _, v := range vowels {
+		if !strings.HasSuffix(base, string(v)) {
+			all = append(all, base+string(v))
+		}
+	}
+	for i := 1; i < len(base); i++ {
+		if isConsonant(base[i]) && (i+1 == len(base) || base[i+1] != base[i]) {
+			all = append(all, base[:i+1]+base[i:])
+		}
+	}
+	for i := 0; i < len(base); i++ {
+		if !isVowel(base[i]) {
+			continue
+		}
+		for _, v := range vowels {
+			if v != base[i] {
+				all = append(all, base[:i]+string(v)+base[i+1:])
+			}
+		}
+	}
+
+	return page(all, round, n)
+}
+
+func page(all []string, round int, n int) []string {
+	start := round * n
+	if start >= len(all) {
+		return nil
+	}
+	return all[start:min(start+n, len(all))]
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func isVowel(b byte) bool {
+	return strings.IndexByte(string(vowels), b) >= 0
+}
+
+func isConsonant(b byte) bool {
+	return b >= 'a' && b <= 'z' && !isVowel(b)
+}
@@ -0,0 +1,112 @@
+package namegen
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		base     string
+		round    int
+		n        int
+		expected []string
+	}{
+		{
+			strategy: "sequential",
+			base:     "mama",
+			round:    1,
+			n:        3,
+			expected: []string{"mama4", "mama5", "mama6"},
+		},
+		{
+			strategy: "wordlist",
+			base:     "mama",
+			round:    0,
+			n:        2,
+			expected: []string{"mama-amani", "mama-baraka"},
+		},
+		{
+			strategy: "mutation",
+			base:     "mama",
+			round:    0,
+			n:        6,
+			expected: []string{"mamae", "mamai", "mamao", "mamau", "mamma", "mema"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			s, err := New(tt.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := s.Candidates(tt.base, tt.round, tt.n); !slices.Equal(got, tt.expected) {
+				t.Errorf("Candidates() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRandomIsDeterministicAndWidens(t *testing.T) {
+	s, _ := New("random")
+
+	first := s.Candidates("mama", 0, 90)
+	if !slices.Equal(first, s.Candidates("mama", 0, 90)) {
+		t.Error("random candidates should be reproducible for the same base and round")
+	}
+	if len(first) != 90 {
+		t.Errorf("round 0 should cover all 90 two digit suffixes, got %d", len(first))
+	}
+
+	for _, c := range s.Candidates("mama", 1, 90) {
+		if len(c) != len("mama")+3 {
+			t.Errorf("round 1 candidate %q should have a three digit suffix", c)
+		}
+	}
+}
+
+func TestRandomPagesThroughEverySuffix(t *testing.T) {
+	s, _ := New("random")
+
+	seen := make(map[string]bool)
+	for round := range 3 {
+		for _, c := range s.Candidates("mama", round, 30) {
+			if len(c) != len("mama")+2 {
+				t.Fatalf("round %d candidate %q should have a two digit suffix", round, c)
+			}
+			if seen[c] {
+				t.Fatalf("round %d repeated %q", round, c)
+			}
+			seen[c] = true
+		}
+	}
+	if len(seen) != 90 {
+		t.Errorf("three rounds of 30 proposed %d distinct two digit suffixes, want all 90", len(seen))
+	}
+
+	for _, c := range s.Candidates("mama", 3, 30) {
+		if len(c) != len("mama")+3 {
+			t.Errorf("round 3 candidate %q should have a three digit suffix", c)
+		}
+	}
+}
+
+func TestGenerateSkipsDuplicatesAndInvalid(t *testing.T) {
+	strategies, err := NewChain([]string{"sequential", "sequential", "mutation"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := Generate(strategies, "mam", 0, 2)
+	expected := []string{"mam1", "mam2", "mama", "mame"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Generate() = %v, want %v", got, expected)
+	}
+
+	if _, err := New("unknown"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
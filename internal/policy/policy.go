@@ -0,0 +1,270 @@
+// Package policy decides whether a label may be registered beyond its syntactic validity.
+//
+// A policy combines static rules from config with reserved names, blocked words and regex rules that can be
+// managed at runtime, see SetDynamic.
+package policy
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type (
+	Opts struct {
+		MinLength int
+		MaxLength int
+		Reserved  []string
+		Blocked   []string
+		// Blocklists are built-in word lists by language code, see the blocklists folder.
+		Blocklists []string
+		Rules      []Rule
+	}
+
+	// Rule rejects labels matching Pattern.
+	Rule struct {
+		Name        string
+		Pattern     string
+		Description string
+	}
+
+	Policy struct {
+		minLength int
+		maxLength int
+
+		static  entries
+		mu      sync.RWMutex
+		dynamic entries
+	}
+
+	Violation struct {
+		Rule        string `json:"rule"`
+		Description string `json:"description"`
+	}
+
+	entries struct {
+		reserved map[string]bool
+		blocked  blocklist
+		rules    []compiledRule
+	}
+
+	blocklist struct {
+		tokens     map[string]bool
+		substrings []string
+	}
+
+	compiledRule struct {
+		Rule
+		re *regexp.Regexp
+	}
+)
+
+const (
+	RuleTooShort = "too_short"
+	RuleTooLong  = "too_long"
+	RuleReserved = "reserved"
+	RuleBlocked  = "blocked"
+
+	defaultMinLength = 3
+	defaultMaxLength = 63
+)
+
+var (
+	DefaultReserved   = []string{"admin", "support", "sarafu", "grassroots", "help", "info", "root", "system"}
+	DefaultBlocklists = []string{"en", "sw"}
+
+	//go:embed blocklists/*.txt
+	blocklistFS embed.FS
+)
+
+func New(o Opts) (*Policy, error) {
+	p := &Policy{
+		minLength: o.MinLength,
+		maxLength: o.MaxLength,
+	}
+	if p.maxLength <= 0 {
+		p.maxLength = defaultMaxLength
+	}
+
+	blocked := o.Blocked
+	for _, lang := range o.Blocklists {
+		words, err := loadBlocklist(lang)
+		if err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, words...)
+	}
+
+	static, err := newEntries(o.Reserved, blocked, o.Rules)
+	if err != nil {
+		return nil, err
+	}
+	p.static = static
+
+	dynamic, _ := newEntries(nil, nil, nil)
+	p.dynamic = dynamic
+
+	return p, nil
+}
+
+// Default is the built-in policy used when nothing is configured.
+func Default() *Policy {
+	p, err := New(Opts{
+		MinLength:  defaultMinLength,
+		Reserved:   DefaultReserved,
+		Blocklists: DefaultBlocklists,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+// SetDynamic replaces the runtime managed entries, static entries from Opts always apply.
+func (p *Policy) SetDynamic(reserved []string, blocked []string, rules []Rule) error {
+	dynamic, err := newEntries(reserved, blocked, rules)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.dynamic = dynamic
+	p.mu.Unlock()
+
+	return nil
+}
+
+// Check returns every rule the label violates, an empty result means it is allowed.
+func (p *Policy) Check(label string) []Violation {
+	var violations []Violation
+
+	if len(label) < p.minLength {
+		violations = append(violations, Violation{
+			Rule:        RuleTooShort,
+			Description: fmt.Sprintf("must be at least %d characters", p.minLength),
+		})
+	}
+
+	if len(label) > p.maxLength {
+		violations = append(violations, Violation{
+			Rule:        RuleTooLong,
+			Description: fmt.Sprintf("must be at most %d characters", p.maxLength),
+		})
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.static.reserved[label] || p.dynamic.reserved[label] {
+		violations = append(violations, Violation{
+			Rule:        RuleReserved,
+			Description: "name is reserved",
+		})
+	}
+
+	tokens := tokenize(label)
+	if p.static.blocked.matches(label, tokens) || p.dynamic.blocked.matches(label, tokens) {
+		violations = append(violations, Violation{
+			Rule:        RuleBlocked,
+			Description: "name contains a blocked word",
+		})
+	}
+
+	for _, rules := range [][]compiledRule{p.static.rules, p.dynamic.rules} {
+		for _, r := range rules {
+			if r.re.MatchString(label) {
+				violations = append(violations, Violation{
+					Rule:        r.Name,
+					Description: r.Description,
+				})
+			}
+		}
+	}
+
+	return violations
+}
+
+func newEntries(reserved []string, blocked []string, rules []Rule) (entries, error) {
+	e := entries{
+		reserved: make(map[string]bool, len(reserved)),
+		blocked: blocklist{
+			tokens: make(map[string]bool),
+		},
+	}
+
+	for _, r := range reserved {
+		e.reserved[strings.ToLower(strings.TrimSpace(r))] = true
+	}
+
+	for _, b := range blocked {
+		b = strings.ToLower(strings.TrimSpace(b))
+		if substring, ok := strings.CutPrefix(b, "*"); ok {
+			e.blocked.substrings = append(e.blocked.substrings, substring)
+		} else if b != "" {
+			e.blocked.tokens[b] = true
+		}
+	}
+
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return entries{}, fmt.Errorf("invalid policy rule %q: %w", r.Name, err)
+		}
+		if r.Name == "" {
+			r.Name = "pattern"
+		}
+		if r.Description == "" {
+			r.Description = "name matches a disallowed pattern"
+		}
+		e.rules = append(e.rules, compiledRule{Rule: r, re: re})
+	}
+
+	return e, nil
+}
+
+// matches blocks plain entries only as whole tokens so that e.g. "ass" does not block "grassroots".
+func (b blocklist) matches(label string, tokens []string) bool {
+	for _, t := range tokens {
+		if b.tokens[t] {
+			return true
+		}
+	}
+	for _, s := range b.substrings {
+		if strings.Contains(label, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenize splits a label on hyphens and digits.
+func tokenize(label string) []string {
+	return strings.FieldsFunc(label, func(r rune) bool {
+		return r == '-' || unicode.IsDigit(r)
+	})
+}
+
+func loadBlocklist(lang string) ([]string, error) {
+	f, err := blocklistFS.Open("blocklists/" + lang + ".txt")
+	if err != nil {
+		return nil, fmt.Errorf("unknown blocklist %q", lang)
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+
+	return words, scanner.Err()
+}
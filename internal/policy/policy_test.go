@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	p, err := New(Opts{
+		MinLength:  3,
+		MaxLength:  16,
+		Reserved:   []string{"admin"},
+		Blocked:    []string{"kuma", "*scam"},
+		Blocklists: []string{"en"},
+		Rules: []Rule{
+			{Name: "impersonation", Pattern: `^sarafu-?(support|team)`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "allowed",
+			input:    "mama-mboga",
+			expected: nil,
+		},
+		{
+			name:     "too short",
+			input:    "ab",
+			expected: []string{RuleTooShort},
+		},
+		{
+			name:     "too long",
+			input:    "averyveryverylongname",
+			expected: []string{RuleTooLong},
+		},
+		{
+			name:     "reserved",
+			input:    "admin",
+			expected: []string{RuleReserved},
+		},
+		{
+			name:     "blocked token",
+			input:    "mama-kuma",
+			expected: []string{RuleBlocked},
+		},
+		{
+			name:     "token match does not apply inside words",
+			input:    "kumar",
+			expected: nil,
+		},
+		{
+			name:     "embedded list whole token only",
+			input:    "grassroots",
+			expected: nil,
+		},
+		{
+			name:     "blocked substring",
+			input:    "bigscammer",
+			expected: []string{RuleBlocked},
+		},
+		{
+			name:     "regex rule",
+			input:    "sarafusupport",
+			expected: []string{"impersonation"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range p.Check(tt.input) {
+				got = append(got, v.Rule)
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Check(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetDynamic(t *testing.T) {
+	p := Default()
+
+	if len(p.Check("mamamboga")) != 0 {
+		t.Fatal("expected name to be allowed before it is reserved")
+	}
+
+	if err := p.SetDynamic([]string{"mamamboga"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Check("mamamboga")) != 1 {
+		t.Error("expected dynamically reserved name to be rejected")
+	}
+
+	if err := p.SetDynamic(nil, nil, []Rule{{Pattern: "("}}); err == nil {
+		t.Error("expected invalid pattern to be rejected")
+	}
+	if len(p.Check("mamamboga")) != 1 {
+		t.Error("a failed update should keep the previous entries")
+	}
+}
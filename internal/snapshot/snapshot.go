@@ -0,0 +1,211 @@
+// Package snapshot encodes exports of the name registry as JSONL or CSV and decodes them for a restore.
+package snapshot
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+)
+
+type (
+	Writer interface {
+		Write(store.ExportedName) error
+		Flush() error
+	}
+
+	jsonlWriter struct {
+		w   *bufio.Writer
+		enc *json.Encoder
+	}
+
+	csvWriter struct {
+		w *csv.Writer
+	}
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// csvHeader lists the CSV columns, texts holds the text records as a JSON object.
+var csvHeader = []string{"name", "address", "owner", "kind", "tenant", "primary", "texts", "expires_at", "created_at", "updated_at"}
+
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	default:
+		return nil, fmt.Errorf("unsupported snapshot format %q", format)
+	}
+}
+
+func (j *jsonlWriter) Write(name store.ExportedName) error {
+	return j.enc.Encode(name)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return j.w.Flush()
+}
+
+func (c *csvWriter) Write(name store.ExportedName) error {
+	texts, err := json.Marshal(name.Texts)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := ""
+	if name.ExpiresAt != nil {
+		expiresAt = name.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return c.w.Write([]string{
+		name.Name,
+		name.Address,
+		name.Owner,
+		name.Kind,
+		name.Tenant,
+		strconv.FormatBool(name.Primary),
+		string(texts),
+		expiresAt,
+		name.CreatedAt.UTC().Format(time.RFC3339Nano),
+		name.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Read decodes a whole snapshot, timestamps are returned in UTC as the store keeps them.
+func Read(r io.Reader, format string) ([]store.ExportedName, error) {
+	var (
+		names []store.ExportedName
+		err   error
+	)
+	switch format {
+	case FormatJSONL:
+		names, err = readJSONL(r)
+	case FormatCSV:
+		names, err = readCSV(r)
+	default:
+		return nil, fmt.Errorf("unsupported snapshot format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range names {
+		if names[i].ExpiresAt != nil {
+			expiresAt := names[i].ExpiresAt.UTC()
+			names[i].ExpiresAt = &expiresAt
+		}
+		names[i].CreatedAt = names[i].CreatedAt.UTC()
+		names[i].UpdatedAt = names[i].UpdatedAt.UTC()
+	}
+
+	return names, nil
+}
+
+func readJSONL(r io.Reader) ([]store.ExportedName, error) {
+	var names []store.ExportedName
+
+	dec := json.NewDecoder(r)
+	for {
+		var name store.ExportedName
+		if err := dec.Decode(&name); err != nil {
+			if errors.Is(err, io.EOF) {
+				return names, nil
+			}
+			return nil, fmt.Errorf("entry %d: %w", len(names)+1, err)
+		}
+		names = append(names, name)
+	}
+}
+
+func readCSV(r io.Reader) ([]store.ExportedName, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	for i, column := range csvHeader {
+		if header[i] != column {
+			return nil, fmt.Errorf("unexpected column %q, want %q", header[i], column)
+		}
+	}
+
+	var names []store.ExportedName
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := parseCSVRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", len(names)+1, err)
+		}
+		names = append(names, name)
+	}
+}
+
+func parseCSVRecord(record []string) (store.ExportedName, error) {
+	name := store.ExportedName{
+		Name:    record[0],
+		Address: record[1],
+		Owner:   record[2],
+		Kind:    record[3],
+		Tenant:  record[4],
+	}
+
+	var err error
+	if name.Primary, err = strconv.ParseBool(record[5]); err != nil {
+		return name, err
+	}
+	if err := json.Unmarshal([]byte(record[6]), &name.Texts); err != nil {
+		return name, err
+	}
+	if record[7] != "" {
+		expiresAt, err := time.Parse(time.RFC3339Nano, record[7])
+		if err != nil {
+			return name, err
+		}
+		name.ExpiresAt = &expiresAt
+	}
+	if name.CreatedAt, err = time.Parse(time.RFC3339Nano, record[8]); err != nil {
+		return name, err
+	}
+	if name.UpdatedAt, err = time.Parse(time.RFC3339Nano, record[9]); err != nil {
+		return name, err
+	}
+
+	return name, nil
+}
@@ -0,0 +1,63 @@
+package snapshot
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+)
+
+func TestRoundTrip(t *testing.T) {
+	expiresAt := time.Date(2027, 3, 1, 12, 0, 0, 0, time.UTC)
+	names := []store.ExportedName{
+		{
+			Name:      "peter.sarafu.eth",
+			Address:   "0xF7D1D901d15BBf60a8e896fbA7BBD4AB4C1021b3",
+			Owner:     "0xF7D1D901d15BBf60a8e896fbA7BBD4AB4C1021b3",
+			Kind:      "user",
+			Tenant:    "apikey:3",
+			Primary:   true,
+			Texts:     map[string]string{"avatar": "https://example.com/peter.png", "description": "a, \"quoted\" bio"},
+			ExpiresAt: &expiresAt,
+			CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 123456000, time.UTC),
+			UpdatedAt: time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC),
+		},
+		{
+			Name:      "mama.vouchers.sarafu.eth",
+			Address:   "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439",
+			Owner:     "0xF7D1D901d15BBf60a8e896fbA7BBD4AB4C1021b3",
+			Kind:      "voucher",
+			Texts:     map[string]string{},
+			CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+			UpdatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	}
+
+	for _, format := range []string{FormatJSONL, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range names {
+				if err := w.Write(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Read(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, names) {
+				t.Errorf("Read() = %+v, want %+v", got, names)
+			}
+		})
+	}
+}
@@ -2,11 +2,16 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/tern/v2/migrate"
 	"github.com/knadh/goyesql/v2"
@@ -18,23 +23,99 @@ type (
 		DSN                  string
 		MigrationsFolderPath string
 		QueriesFolderPath    string
+		// RenameGracePeriod is how long an old name keeps resolving after a rename.
+		RenameGracePeriod time.Duration
+		// RenameCooldown is the minimum time between two renames by the same owner, across all of its names.
+		RenameCooldown time.Duration
+		// Leases are the registration periods by name kind, names of other kinds never expire.
+		Leases map[string]time.Duration
+		// ExpiryGracePeriod is how long an expired name keeps resolving before it is released.
+		ExpiryGracePeriod time.Duration
 	}
 
 	Pg struct {
 		logg    *slog.Logger
 		db      *pgxpool.Pool
 		queries *queries
+
+		renameGracePeriod time.Duration
+		renameCooldown    time.Duration
+		leases            map[string]time.Duration
+		expiryGracePeriod time.Duration
+	}
+
+	lockedAlias struct {
+		id          int
+		primaryName string
+		owner       string
 	}
 
 	queries struct {
-		RegisterName  string `query:"register-name"`
-		UpdateName    string `query:"update-name"`
-		UpsertName    string `query:"upsert-name"`
-		LookupName    string `query:"lookup-name"`
-		ReverseLookup string `query:"reverse-lookup"`
+		RegisterName               string `query:"register-name"`
+		LockPrimaryAlias           string `query:"lock-primary-alias"`
+		LockOwnerRenames           string `query:"lock-owner-renames"`
+		LockAliasByName            string `query:"lock-alias-by-name"`
+		RenameAlias                string `query:"rename-alias"`
+		CreateRedirect             string `query:"create-redirect"`
+		ReclaimRedirect            string `query:"reclaim-redirect"`
+		PurgeExpiredRedirects      string `query:"purge-expired-redirects"`
+		RenewName                  string `query:"renew-name"`
+		ReleaseExpiredNames        string `query:"release-expired-names"`
+		CreateTransfer             string `query:"create-transfer"`
+		LookupTransfer             string `query:"lookup-transfer"`
+		LockPendingTransfer        string `query:"lock-pending-transfer"`
+		FinishTransfer             string `query:"finish-transfer"`
+		ExpireTransfers            string `query:"expire-transfers"`
+		LockAliasByID              string `query:"lock-alias-by-id"`
+		MoveAlias                  string `query:"move-alias"`
+		RecordAliasEvent           string `query:"record-alias-event"`
+		ListAliasEvents            string `query:"list-alias-events"`
+		LookupName                 string `query:"lookup-name"`
+		ReverseLookup              string `query:"reverse-lookup"`
+		BatchLookupNames           string `query:"batch-lookup-names"`
+		ListNames                  string `query:"list-names"`
+		BatchReverseLookup         string `query:"batch-reverse-lookup"`
+		ExportNames                string `query:"export-names"`
+		HasNames                   string `query:"has-names"`
+		RestoreName                string `query:"restore-name"`
+		ListAddressNames           string `query:"list-address-names"`
+		LookupNameRecord           string `query:"lookup-name-record"`
+		LockName                   string `query:"lock-name"`
+		SetNameAddress             string `query:"set-name-address"`
+		PromotePrimaryName         string `query:"promote-primary-name"`
+		ClearPrimaryName           string `query:"clear-primary-name"`
+		SetPrimaryName             string `query:"set-primary-name"`
+		RegisterFirstAvailable     string `query:"register-first-available"`
+		TakenNames                 string `query:"taken-names"`
+		NameReserved               string `query:"name-reserved"`
+		ReserveName                string `query:"reserve-name"`
+		ConsumeReservation         string `query:"consume-reservation"`
+		ReleaseReservation         string `query:"release-reservation"`
+		PurgeExpiredReservations   string `query:"purge-expired-reservations"`
+		ListPolicyEntries          string `query:"list-policy-entries"`
+		AddPolicyEntry             string `query:"add-policy-entry"`
+		DeletePolicyEntry          string `query:"delete-policy-entry"`
+		CreateAPIKey               string `query:"create-api-key"`
+		ListAPIKeys                string `query:"list-api-keys"`
+		RevokeAPIKey               string `query:"revoke-api-key"`
+		UseAPIKey                  string `query:"use-api-key"`
+		LookupTextRecords          string `query:"lookup-text-records"`
+		SetTextRecord              string `query:"set-text-record"`
+		DeleteTextRecord           string `query:"delete-text-record"`
+		CreateSIWEChallenge        string `query:"create-siwe-challenge"`
+		ConsumeSIWEChallenge       string `query:"consume-siwe-challenge"`
+		PurgeExpiredSIWEChallenges string `query:"purge-expired-siwe-challenges"`
+		ClaimIdempotencyKey        string `query:"claim-idempotency-key"`
+		LookupIdempotencyKey       string `query:"lookup-idempotency-key"`
+		CompleteIdempotencyKey     string `query:"complete-idempotency-key"`
+		ReleaseIdempotencyKey      string `query:"release-idempotency-key"`
+		PurgeIdempotencyKeys       string `query:"purge-idempotency-keys"`
 	}
 )
 
+// likeEscaper escapes LIKE wildcards in search input, backslash is the default LIKE escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 func NewPgStore(o PgOpts) (Store, error) {
 	parsedConfig, err := pgxpool.ParseConfig(o.DSN)
 	if err != nil {
@@ -60,6 +141,11 @@ func NewPgStore(o PgOpts) (Store, error) {
 		logg:    o.Logg,
 		db:      dbPool,
 		queries: queries,
+
+		renameGracePeriod: o.RenameGracePeriod,
+		renameCooldown:    o.RenameCooldown,
+		leases:            o.Leases,
+		expiryGracePeriod: o.ExpiryGracePeriod,
 	}, nil
 }
 
@@ -67,45 +153,529 @@ func (pg *Pg) Close() {
 	pg.db.Close()
 }
 
-func (pg *Pg) RegisterName(ctx context.Context, primaryName string, blockchainAddress string) error {
-	_, err := pg.db.Exec(
+// RegisterName registers a name of the given kind together with its initial text records.
+func (pg *Pg) RegisterName(ctx context.Context, name NewName) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		if err := pg.ensureNotReserved(ctx, tx, name.Name, nil); err != nil {
+			return err
+		}
+
+		return pg.insertName(ctx, tx, name)
+	})
+}
+
+// RegisterNames registers all names in a single transaction, nothing is registered when one of them fails.
+func (pg *Pg) RegisterNames(ctx context.Context, names []NewName) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		for _, n := range names {
+			if err := pg.ensureNotReserved(ctx, tx, n.Name, nil); err != nil {
+				return fmt.Errorf("%s: %w", n.Name, err)
+			}
+
+			if err := pg.insertName(ctx, tx, n); err != nil {
+				return fmt.Errorf("%s: %w", n.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (pg *Pg) insertName(ctx context.Context, tx pgx.Tx, name NewName) error {
+	_, err := tx.Exec(
 		ctx,
 		pg.queries.RegisterName,
-		primaryName,
-		blockchainAddress,
+		name.Name,
+		name.Address,
+		name.Kind,
+		pg.lease(name.Kind),
+		name.Tenant,
 	)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return pg.setTextRecords(ctx, tx, name.Name, name.Texts)
 }
 
-func (pg *Pg) UpdateName(ctx context.Context, primaryName string, blockchainAddress string) error {
-	_, err := pg.db.Exec(
+// UpdateName renames currentName to primaryName, or the primary name of owner when currentName is empty. Only
+// the owner of the name can rename it, wherever it resolves to. It returns pgx.ErrNoRows when there is no such
+// name.
+func (pg *Pg) UpdateName(ctx context.Context, currentName string, primaryName string, owner string) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		query, arg := pg.queries.LockAliasByName, currentName
+		if currentName == "" {
+			query, arg = pg.queries.LockPrimaryAlias, owner
+		}
+
+		current, err := pg.lockAlias(ctx, tx, query, arg)
+		if err != nil {
+			return err
+		}
+
+		if current.owner != owner {
+			return ErrNotOwner
+		}
+
+		return pg.renameAlias(ctx, tx, current, primaryName)
+	})
+}
+
+// UpsertName registers the name as the primary name of the address, or renames its current primary name.
+// It fails with ErrNotOwner when the primary name of the address is owned by someone else.
+func (pg *Pg) UpsertName(ctx context.Context, primaryName string, blockchainAddress string, tenant string) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		current, err := pg.lockAlias(ctx, tx, pg.queries.LockPrimaryAlias, blockchainAddress)
+		if err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				return err
+			}
+
+			if err := pg.ensureNotReserved(ctx, tx, primaryName, nil); err != nil {
+				return err
+			}
+
+			return pg.insertName(ctx, tx, NewName{
+				Name:    primaryName,
+				Address: blockchainAddress,
+				Kind:    "user",
+				Tenant:  tenant,
+			})
+		}
+
+		if current.owner != blockchainAddress {
+			return ErrNotOwner
+		}
+
+		return pg.renameAlias(ctx, tx, current, primaryName)
+	})
+}
+
+// RenameName changes a single name in place, keeping its address, primary flag and text records.
+func (pg *Pg) RenameName(ctx context.Context, primaryName string, newPrimaryName string) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		current, err := pg.lockAlias(ctx, tx, pg.queries.LockAliasByName, primaryName)
+		if err != nil {
+			return err
+		}
+
+		return pg.renameAlias(ctx, tx, current, newPrimaryName)
+	})
+}
+
+// RenewName extends the lease of a name by the lease of its kind. It returns ErrNoLease for names that
+// never expire.
+func (pg *Pg) RenewName(ctx context.Context, primaryName string, kind string) (time.Time, error) {
+	lease, ok := pg.leases[kind]
+	if !ok || lease <= 0 {
+		return time.Time{}, ErrNoLease
+	}
+
+	var expiresAt time.Time
+	err := pg.db.QueryRow(
 		ctx,
-		pg.queries.UpdateName,
+		pg.queries.RenewName,
 		primaryName,
-		blockchainAddress,
+		lease.Seconds(),
+	).Scan(&expiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, ErrNoLease
+		}
+		return time.Time{}, err
+	}
+
+	return expiresAt, nil
+}
+
+// ReleaseExpiredNames deactivates names whose grace period has ended so that they can be registered again.
+// Addresses that lose their primary name fall back to their oldest remaining name.
+func (pg *Pg) ReleaseExpiredNames(ctx context.Context) (int64, error) {
+	var released int64
+	err := pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, pg.queries.ReleaseExpiredNames, pg.expiryGracePeriod.Seconds())
+		if err != nil {
+			return err
+		}
+
+		addresses, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+		released = int64(len(addresses))
+
+		for _, address := range slices.Compact(slices.Sorted(slices.Values(addresses))) {
+			if _, err := tx.Exec(ctx, pg.queries.PromotePrimaryName, address); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return released, nil
+}
+
+// lease returns the lease of kind in seconds, or nil when its names never expire.
+func (pg *Pg) lease(kind string) any {
+	if lease, ok := pg.leases[kind]; ok && lease > 0 {
+		return lease.Seconds()
+	}
+	return nil
+}
+
+func (pg *Pg) PurgeExpiredRedirects(ctx context.Context) (int64, error) {
+	tag, err := pg.db.Exec(ctx, pg.queries.PurgeExpiredRedirects)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (pg *Pg) lockAlias(ctx context.Context, tx pgx.Tx, query string, arg string) (lockedAlias, error) {
+	var alias lockedAlias
+	err := tx.QueryRow(ctx, query, arg).Scan(&alias.id, &alias.primaryName, &alias.owner)
+	return alias, err
+}
+
+// renameAlias moves a locked alias to a new name. The old name keeps resolving to the alias and stays
+// unavailable for the rename grace period, and an owner can only rename one of its names once per cooldown.
+// Renaming back to a name still in its grace period reclaims it.
+func (pg *Pg) renameAlias(ctx context.Context, tx pgx.Tx, alias lockedAlias, newPrimaryName string) error {
+	if alias.primaryName == newPrimaryName {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, pg.queries.ReclaimRedirect, newPrimaryName, alias.id); err != nil {
+		return err
+	}
+
+	if err := pg.ensureNotReserved(ctx, tx, newPrimaryName, nil); err != nil {
+		return err
+	}
+
+	// The cooldown limits how often an owner renames, not each of its names.
+	if _, err := tx.Exec(ctx, pg.queries.LockOwnerRenames, alias.owner); err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(
+		ctx,
+		pg.queries.RenameAlias,
+		alias.id,
+		newPrimaryName,
+		pg.renameCooldown.Seconds(),
+		alias.owner,
 	)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrRenameCooldown
+	}
 
-	return nil
+	if pg.renameGracePeriod <= 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		pg.queries.CreateRedirect,
+		alias.primaryName,
+		alias.id,
+		pg.renameGracePeriod.Seconds(),
+	)
+	return err
 }
 
-func (pg *Pg) UpsertName(ctx context.Context, primaryName string, blockchainAddress string) error {
-	_, err := pg.db.Exec(
+// RegisterReservedName consumes a live reservation for the name and registers it in the same transaction.
+func (pg *Pg) RegisterReservedName(ctx context.Context, name NewName, token string) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		var reserved string
+		if err := tx.QueryRow(ctx, pg.queries.ConsumeReservation, name.Name, token).Scan(&reserved); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrReservationNotFound
+			}
+			return err
+		}
+
+		return pg.insertName(ctx, tx, name)
+	})
+}
+
+// ReserveName holds a free name for ttl and returns when the reservation expires.
+func (pg *Pg) ReserveName(ctx context.Context, primaryName string, token string, subject string, ttl time.Duration) (time.Time, error) {
+	var expiresAt time.Time
+	err := pg.db.QueryRow(
 		ctx,
-		pg.queries.UpsertName,
+		pg.queries.ReserveName,
 		primaryName,
-		blockchainAddress,
+		token,
+		subject,
+		ttl.Seconds(),
+	).Scan(&expiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, ErrNameUnavailable
+		}
+		return time.Time{}, err
+	}
+
+	return expiresAt, nil
+}
+
+func (pg *Pg) ReleaseReservation(ctx context.Context, token string) (bool, error) {
+	tag, err := pg.db.Exec(
+		ctx,
+		pg.queries.ReleaseReservation,
+		token,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (pg *Pg) PurgeExpiredReservations(ctx context.Context) (int64, error) {
+	tag, err := pg.db.Exec(ctx, pg.queries.PurgeExpiredReservations)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// CreateTransfer starts a transfer of transfer.Name from transfer.FromOwner, which must be its current owner,
+// that can be accepted for ttl, and fills in the generated fields.
+func (pg *Pg) CreateTransfer(ctx context.Context, transfer *Transfer, ttl time.Duration) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		alias, err := pg.lockAlias(ctx, tx, pg.queries.LockAliasByName, transfer.Name)
+		if err != nil {
+			return err
+		}
+
+		if alias.owner != transfer.FromOwner {
+			return ErrNotOwner
+		}
+
+		err = tx.QueryRow(
+			ctx,
+			pg.queries.CreateTransfer,
+			alias.id,
+			transfer.FromOwner,
+			transfer.ToAddress,
+			transfer.InitiatedBy,
+			ttl.Seconds(),
+		).Scan(&transfer.ID, &transfer.Status, &transfer.CreatedAt, &transfer.ExpiresAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrTransferPending
+			}
+			return err
+		}
+
+		return pg.recordAliasEvent(ctx, tx, alias.id, "transfer_initiated", map[string]any{
+			"transferId": transfer.ID,
+			"from":       transfer.FromOwner,
+			"to":         transfer.ToAddress,
+		}, transfer.InitiatedBy)
+	})
+}
+
+func (pg *Pg) LookupTransfer(ctx context.Context, id int) (*Transfer, error) {
+	var transfer Transfer
+	err := pg.db.QueryRow(
+		ctx,
+		pg.queries.LookupTransfer,
+		id,
+	).Scan(
+		&transfer.ID,
+		&transfer.Name,
+		&transfer.FromOwner,
+		&transfer.ToAddress,
+		&transfer.Status,
+		&transfer.InitiatedBy,
+		&transfer.CreatedAt,
+		&transfer.ExpiresAt,
+		&transfer.CompletedAt,
 	)
 	if err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// AcceptTransfer moves the name to the target address as its new owner and resolved address. The transfer
+// fails with ErrTransferNotFound once it is no longer pending and with ErrNotOwner when the name changed
+// owner since it was initiated.
+func (pg *Pg) AcceptTransfer(ctx context.Context, id int, actor string) (*Transfer, error) {
+	err := pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		var aliasID int
+		var fromOwner, toAddress string
+		if err := tx.QueryRow(ctx, pg.queries.LockPendingTransfer, id).Scan(&aliasID, &fromOwner, &toAddress); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTransferNotFound
+			}
+			return err
+		}
+
+		var alias lockedAlias
+		var previousAddress string
+		err := tx.QueryRow(ctx, pg.queries.LockAliasByID, aliasID).Scan(&alias.id, &alias.primaryName, &alias.owner, &previousAddress)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTransferNotFound
+			}
+			return err
+		}
+
+		if alias.owner != fromOwner {
+			return ErrNotOwner
+		}
+
+		if _, err := tx.Exec(ctx, pg.queries.MoveAlias, alias.id, toAddress); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, pg.queries.PromotePrimaryName, previousAddress); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, pg.queries.FinishTransfer, id, "accepted"); err != nil {
+			return err
+		}
+
+		return pg.recordAliasEvent(ctx, tx, alias.id, "transferred", map[string]any{
+			"transferId":  id,
+			"fromOwner":   fromOwner,
+			"fromAddress": previousAddress,
+			"to":          toAddress,
+		}, actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.LookupTransfer(ctx, id)
+}
+
+func (pg *Pg) CancelTransfer(ctx context.Context, id int, actor string) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		var aliasID int
+		if err := tx.QueryRow(ctx, pg.queries.FinishTransfer, id, "cancelled").Scan(&aliasID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTransferNotFound
+			}
+			return err
+		}
+
+		return pg.recordAliasEvent(ctx, tx, aliasID, "transfer_cancelled", map[string]any{
+			"transferId": id,
+		}, actor)
+	})
+}
+
+func (pg *Pg) ExpireTransfers(ctx context.Context) (int64, error) {
+	tag, err := pg.db.Exec(ctx, pg.queries.ExpireTransfers)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ListAliasEvents returns the history of a name, oldest first.
+func (pg *Pg) ListAliasEvents(ctx context.Context, primaryName string) ([]AliasEvent, error) {
+	rows, err := pg.db.Query(ctx, pg.queries.ListAliasEvents, primaryName)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (AliasEvent, error) {
+		var event AliasEvent
+		err := row.Scan(
+			&event.Event,
+			&event.Data,
+			&event.Actor,
+			&event.CreatedAt,
+		)
+		return event, err
+	})
+}
+
+func (pg *Pg) recordAliasEvent(ctx context.Context, tx pgx.Tx, aliasID int, event string, data map[string]any, actor string) error {
+	_, err := tx.Exec(
+		ctx,
+		pg.queries.RecordAliasEvent,
+		aliasID,
+		event,
+		data,
+		actor,
+	)
+	return err
+}
+
+func (pg *Pg) ListPolicyEntries(ctx context.Context) ([]PolicyEntry, error) {
+	rows, err := pg.db.Query(ctx, pg.queries.ListPolicyEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (PolicyEntry, error) {
+		var entry PolicyEntry
+		err := row.Scan(
+			&entry.ID,
+			&entry.List,
+			&entry.Value,
+			&entry.Description,
+			&entry.CreatedAt,
+		)
+		return entry, err
+	})
+}
+
+func (pg *Pg) AddPolicyEntry(ctx context.Context, entry *PolicyEntry) error {
+	return pg.db.QueryRow(
+		ctx,
+		pg.queries.AddPolicyEntry,
+		entry.List,
+		entry.Value,
+		entry.Description,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (pg *Pg) DeletePolicyEntry(ctx context.Context, id int) (bool, error) {
+	tag, err := pg.db.Exec(
+		ctx,
+		pg.queries.DeletePolicyEntry,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// ensureNotReserved fails with ErrNameReserved when someone other than the holder of token has a live
+// reservation on the name.
+func (pg *Pg) ensureNotReserved(ctx context.Context, tx pgx.Tx, primaryName string, token *string) error {
+	var reserved bool
+	if err := tx.QueryRow(ctx, pg.queries.NameReserved, primaryName, token).Scan(&reserved); err != nil {
 		return err
 	}
 
+	if reserved {
+		return ErrNameReserved
+	}
+
 	return nil
 }
 
@@ -115,6 +685,7 @@ func (pg *Pg) LookupName(ctx context.Context, primaryName string) (string, error
 		ctx,
 		pg.queries.LookupName,
 		primaryName,
+		pg.expiryGracePeriod.Seconds(),
 	).Scan(&blockchainAddress)
 	if err != nil {
 		return "", err
@@ -123,13 +694,62 @@ func (pg *Pg) LookupName(ctx context.Context, primaryName string) (string, error
 	return blockchainAddress, nil
 }
 
-// TODO: If an address has multiple registered names, should we return them all? Should we allow this feature in the first place?
+func (pg *Pg) LookupNameRecord(ctx context.Context, primaryName string) (*NameRecord, error) {
+	var record NameRecord
+	err := pg.db.QueryRow(
+		ctx,
+		pg.queries.LookupNameRecord,
+		primaryName,
+		pg.expiryGracePeriod.Seconds(),
+	).Scan(
+		&record.Name,
+		&record.Address,
+		&record.Owner,
+		&record.Kind,
+		&record.Primary,
+		&record.ExpiresAt,
+		&record.Expired,
+		&record.CreatedAt,
+		&record.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// SetNameAddress points a name at another address on behalf of its owner. It returns pgx.ErrNoRows for an
+// unknown name and ErrNotOwner when owner does not control it. The name becomes the primary name of the new
+// address if it has none, and the previous address falls back to its oldest remaining name.
+func (pg *Pg) SetNameAddress(ctx context.Context, primaryName string, owner string, blockchainAddress string) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		var previousAddress, currentOwner string
+		if err := tx.QueryRow(ctx, pg.queries.LockName, primaryName).Scan(&previousAddress, &currentOwner); err != nil {
+			return err
+		}
+
+		if currentOwner != owner {
+			return ErrNotOwner
+		}
+
+		if _, err := tx.Exec(ctx, pg.queries.SetNameAddress, primaryName, blockchainAddress); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, pg.queries.PromotePrimaryName, previousAddress)
+		return err
+	})
+}
+
+// ReverseLookup returns the primary name of the address.
 func (pg *Pg) ReverseLookup(ctx context.Context, blockchainAddress string) (string, error) {
 	var primaryName string
 	err := pg.db.QueryRow(
 		ctx,
 		pg.queries.ReverseLookup,
 		blockchainAddress,
+		pg.expiryGracePeriod.Seconds(),
 	).Scan(&primaryName)
 	if err != nil {
 		return "", err
@@ -138,6 +758,477 @@ func (pg *Pg) ReverseLookup(ctx context.Context, blockchainAddress string) (stri
 	return primaryName, nil
 }
 
+// BatchLookupNames resolves several names in one query, names that do not resolve are left out of the result.
+func (pg *Pg) BatchLookupNames(ctx context.Context, primaryNames []string) (map[string]string, error) {
+	return pg.collectPairs(ctx, pg.queries.BatchLookupNames, primaryNames)
+}
+
+// BatchReverseLookup returns the primary name of each address that has one.
+func (pg *Pg) BatchReverseLookup(ctx context.Context, blockchainAddresses []string) (map[string]string, error) {
+	return pg.collectPairs(ctx, pg.queries.BatchReverseLookup, blockchainAddresses)
+}
+
+func (pg *Pg) collectPairs(ctx context.Context, query string, keys []string) (map[string]string, error) {
+	rows, err := pg.db.Query(ctx, query, keys, pg.expiryGracePeriod.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pairs := make(map[string]string, len(keys))
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		pairs[key] = value
+	}
+
+	return pairs, rows.Err()
+}
+
+// ListNames returns a page of names matching the filter, continuing after the cursor of the previous page.
+func (pg *Pg) ListNames(ctx context.Context, filter NameFilter) ([]ListedName, error) {
+	column, cast := "alias.primary_name", ""
+	switch filter.Sort {
+	case NameSortCreatedAt:
+		column, cast = "alias.created_at", "::TIMESTAMP"
+	case NameSortUpdatedAt:
+		column, cast = "alias.updated_at", "::TIMESTAMP"
+	}
+	op, direction := ">", "ASC"
+	if filter.Descending {
+		op, direction = "<", "DESC"
+	}
+
+	query := pg.queries.ListNames
+	args := []any{
+		escapeLike(filter.Prefix),
+		escapeLike(filter.Contains),
+		utcOrNil(filter.CreatedAfter),
+		utcOrNil(filter.CreatedBefore),
+		utcOrNil(filter.UpdatedAfter),
+		utcOrNil(filter.UpdatedBefore),
+		filter.Active,
+		filter.Tenant,
+		filter.Kind,
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.Value, filter.After.ID)
+		query += fmt.Sprintf(" AND (%s, alias.id) %s ($%d%s, $%d)", column, op, len(args)-1, cast, len(args))
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY %s %s, alias.id %s LIMIT $%d", column, direction, direction, len(args))
+
+	rows, err := pg.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (ListedName, error) {
+		var name ListedName
+		err := row.Scan(
+			&name.ID,
+			&name.Name,
+			&name.Address,
+			&name.Owner,
+			&name.Kind,
+			&name.Tenant,
+			&name.Primary,
+			&name.Active,
+			&name.ExpiresAt,
+			&name.CreatedAt,
+			&name.UpdatedAt,
+		)
+		return name, err
+	})
+}
+
+// ListAddressNames returns the active names of the address, optionally of a single kind, the primary name
+// first.
+func (pg *Pg) ListAddressNames(ctx context.Context, blockchainAddress string, kind string) ([]AddressName, error) {
+	rows, err := pg.db.Query(ctx, pg.queries.ListAddressNames, blockchainAddress, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (AddressName, error) {
+		var name AddressName
+		err := row.Scan(
+			&name.Name,
+			&name.Owner,
+			&name.Kind,
+			&name.Primary,
+			&name.CreatedAt,
+		)
+		return name, err
+	})
+}
+
+// SetPrimaryName moves the primary flag of the address to one of its names. It returns pgx.ErrNoRows when
+// the name is not an active name of the address.
+func (pg *Pg) SetPrimaryName(ctx context.Context, primaryName string, blockchainAddress string) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, pg.queries.ClearPrimaryName, blockchainAddress); err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(
+			ctx,
+			pg.queries.SetPrimaryName,
+			primaryName,
+			blockchainAddress,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+
+		return nil
+	})
+}
+
+// RegisterFirstAvailable registers the first free name out of candidates as a user name together with its
+// initial text records, the Name and Kind of name are ignored. It returns pgx.ErrNoRows when every candidate is
+// taken, including when it lost a race to a concurrent registration.
+func (pg *Pg) RegisterFirstAvailable(ctx context.Context, candidates []string, name NewName) (string, error) {
+	var primaryName string
+	err := pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		err := tx.QueryRow(
+			ctx,
+			pg.queries.RegisterFirstAvailable,
+			candidates,
+			name.Address,
+			pg.lease("user"),
+			name.Tenant,
+		).Scan(&primaryName)
+		if err != nil {
+			return err
+		}
+
+		return pg.setTextRecords(ctx, tx, primaryName, name.Texts)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return primaryName, nil
+}
+
+// TakenNames returns the subset of names that are already registered.
+func (pg *Pg) TakenNames(ctx context.Context, primaryNames []string) ([]string, error) {
+	rows, err := pg.db.Query(ctx, pg.queries.TakenNames, primaryNames)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// CreateAPIKey stores the hash of a newly generated key and fills in the ID and creation time.
+func (pg *Pg) CreateAPIKey(ctx context.Context, apiKey *APIKey, keyHash string) error {
+	return pg.db.QueryRow(
+		ctx,
+		pg.queries.CreateAPIKey,
+		apiKey.Name,
+		keyHash,
+		apiKey.Scopes,
+		apiKey.ExpiresAt,
+	).Scan(&apiKey.ID, &apiKey.CreatedAt)
+}
+
+func (pg *Pg) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := pg.db.Query(ctx, pg.queries.ListAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (APIKey, error) {
+		var apiKey APIKey
+		err := row.Scan(
+			&apiKey.ID,
+			&apiKey.Name,
+			&apiKey.Scopes,
+			&apiKey.Active,
+			&apiKey.CreatedAt,
+			&apiKey.ExpiresAt,
+			&apiKey.LastUsedAt,
+		)
+		return apiKey, err
+	})
+}
+
+func (pg *Pg) RevokeAPIKey(ctx context.Context, id int) (bool, error) {
+	tag, err := pg.db.Exec(
+		ctx,
+		pg.queries.RevokeAPIKey,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// UseAPIKey returns the active, unexpired key matching the hash and records its last use, to the minute.
+func (pg *Pg) UseAPIKey(ctx context.Context, keyHash string) (*APIKey, error) {
+	apiKey := APIKey{Active: true}
+	err := pg.db.QueryRow(
+		ctx,
+		pg.queries.UseAPIKey,
+		keyHash,
+	).Scan(&apiKey.ID, &apiKey.Name, &apiKey.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+// ExportNames calls fn for every active name, all from the same snapshot even while names keep changing.
+func (pg *Pg) ExportNames(ctx context.Context, fn func(ExportedName) error) error {
+	return pgx.BeginTxFunc(ctx, pg.db, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	}, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, pg.queries.ExportNames)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name ExportedName
+			if err := rows.Scan(
+				&name.Name,
+				&name.Address,
+				&name.Owner,
+				&name.Kind,
+				&name.Tenant,
+				&name.Primary,
+				&name.Texts,
+				&name.ExpiresAt,
+				&name.CreatedAt,
+				&name.UpdatedAt,
+			); err != nil {
+				return err
+			}
+
+			if err := fn(name); err != nil {
+				return err
+			}
+		}
+
+		return rows.Err()
+	})
+}
+
+// RestoreNames loads an export into an empty store in a single transaction, it fails with ErrStoreNotEmpty
+// when any name, even an inactive one, exists.
+func (pg *Pg) RestoreNames(ctx context.Context, names []ExportedName) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		var hasNames bool
+		if err := tx.QueryRow(ctx, pg.queries.HasNames).Scan(&hasNames); err != nil {
+			return err
+		}
+		if hasNames {
+			return ErrStoreNotEmpty
+		}
+
+		for _, n := range names {
+			_, err := tx.Exec(
+				ctx,
+				pg.queries.RestoreName,
+				n.Name,
+				n.Address,
+				n.Owner,
+				n.Kind,
+				n.Primary,
+				n.ExpiresAt,
+				n.CreatedAt,
+				n.UpdatedAt,
+				n.Tenant,
+			)
+			if err != nil {
+				return fmt.Errorf("%s: %w", n.Name, err)
+			}
+
+			if err := pg.setTextRecords(ctx, tx, n.Name, n.Texts); err != nil {
+				return fmt.Errorf("%s: %w", n.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (pg *Pg) LookupTextRecords(ctx context.Context, primaryName string) (map[string]string, error) {
+	rows, err := pg.db.Query(ctx, pg.queries.LookupTextRecords, primaryName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		records[key] = value
+	}
+
+	return records, rows.Err()
+}
+
+// SetTextRecords applies all records in a single transaction, an empty value removes the record.
+func (pg *Pg) SetTextRecords(ctx context.Context, primaryName string, records map[string]string) error {
+	return pgx.BeginFunc(ctx, pg.db, func(tx pgx.Tx) error {
+		return pg.setTextRecords(ctx, tx, primaryName, records)
+	})
+}
+
+func (pg *Pg) setTextRecords(ctx context.Context, tx pgx.Tx, primaryName string, records map[string]string) error {
+	for key, value := range records {
+		query, args := pg.queries.SetTextRecord, []any{primaryName, key, value}
+		if value == "" {
+			query, args = pg.queries.DeleteTextRecord, []any{primaryName, key}
+		}
+
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (pg *Pg) CreateSIWEChallenge(ctx context.Context, challenge SIWEChallenge) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.CreateSIWEChallenge,
+		challenge.Nonce,
+		challenge.Name,
+		challenge.Signer,
+		challenge.Statement,
+		challenge.Action,
+		challenge.TTL.Seconds(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeSIWEChallenge marks an unexpired challenge as used so that it can never be replayed. Only the signer
+// the challenge was issued to can consume it, with the statement it was issued with.
+func (pg *Pg) ConsumeSIWEChallenge(ctx context.Context, nonce string, signer string, statement string) (string, []byte, error) {
+	var (
+		primaryName string
+		action      []byte
+	)
+	err := pg.db.QueryRow(
+		ctx,
+		pg.queries.ConsumeSIWEChallenge,
+		nonce,
+		signer,
+		statement,
+	).Scan(&primaryName, &action)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return primaryName, action, nil
+}
+
+func (pg *Pg) PurgeExpiredSIWEChallenges(ctx context.Context) (int64, error) {
+	tag, err := pg.db.Exec(ctx, pg.queries.PurgeExpiredSIWEChallenges)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ClaimIdempotencyKey returns nil when the key was free (or older than window, or claimed longer than lease ago
+// by a request that never completed) and is now claimed by the caller, otherwise it returns the record stored
+// for the earlier request.
+func (pg *Pg) ClaimIdempotencyKey(ctx context.Context, subject string, key string, requestHash string, window time.Duration, lease time.Duration) (*IdempotencyRecord, error) {
+	var claimed bool
+	err := pg.db.QueryRow(
+		ctx,
+		pg.queries.ClaimIdempotencyKey,
+		subject,
+		key,
+		requestHash,
+		window.Seconds(),
+		lease.Seconds(),
+	).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	var record IdempotencyRecord
+	err = pg.db.QueryRow(
+		ctx,
+		pg.queries.LookupIdempotencyKey,
+		subject,
+		key,
+	).Scan(&record.RequestHash, &record.StatusCode, &record.Response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (pg *Pg) CompleteIdempotencyKey(ctx context.Context, subject string, key string, statusCode int, response []byte) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.CompleteIdempotencyKey,
+		subject,
+		key,
+		statusCode,
+		response,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey frees an in flight key so that a failed request can be retried with it.
+func (pg *Pg) ReleaseIdempotencyKey(ctx context.Context, subject string, key string) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.ReleaseIdempotencyKey,
+		subject,
+		key,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (pg *Pg) PurgeIdempotencyKeys(ctx context.Context, window time.Duration) (int64, error) {
+	tag, err := pg.db.Exec(ctx, pg.queries.PurgeIdempotencyKeys, window.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
 func loadQueries(queriesPath string) (*queries, error) {
 	parsedQueries, err := goyesql.ParseFile(queriesPath)
 	if err != nil {
@@ -180,3 +1271,15 @@ func runMigrations(ctx context.Context, dbPool *pgxpool.Pool, migrationsPath str
 
 	return nil
 }
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// utcOrNil keeps filter times comparable with the TIMESTAMP columns, which hold UTC.
+func utcOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
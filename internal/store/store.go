@@ -2,15 +2,217 @@ package store
 
 import (
 	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNameReserved        = errors.New("name is reserved")
+	ErrNameUnavailable     = errors.New("name is already registered or reserved")
+	ErrReservationNotFound = errors.New("reservation not found or expired")
+	ErrNotOwner            = errors.New("not the owner of the name")
+	ErrRenameCooldown      = errors.New("owner renamed a name too recently")
+	ErrNoLease             = errors.New("name does not expire")
+	ErrTransferPending     = errors.New("name already has a pending transfer")
+	ErrTransferNotFound    = errors.New("transfer not found, expired or already finished")
+	ErrStoreNotEmpty       = errors.New("store already holds names")
+)
+
+const (
+	NameSortName      = "name"
+	NameSortCreatedAt = "created_at"
+	NameSortUpdatedAt = "updated_at"
 )
 
 type (
 	Store interface {
-		RegisterName(context.Context, string, string) error
-		UpdateName(context.Context, string, string) error
-		UpsertName(context.Context, string, string) error
+		RegisterName(context.Context, NewName) error
+		RegisterNames(context.Context, []NewName) error
+		UpdateName(context.Context, string, string, string) error
+		UpsertName(context.Context, string, string, string) error
+		RenameName(context.Context, string, string) error
 		LookupName(context.Context, string) (string, error)
+		LookupNameRecord(context.Context, string) (*NameRecord, error)
+		SetNameAddress(context.Context, string, string, string) error
 		ReverseLookup(context.Context, string) (string, error)
+		BatchLookupNames(context.Context, []string) (map[string]string, error)
+		BatchReverseLookup(context.Context, []string) (map[string]string, error)
+		ListAddressNames(context.Context, string, string) ([]AddressName, error)
+		ListNames(context.Context, NameFilter) ([]ListedName, error)
+		SetPrimaryName(context.Context, string, string) error
+		RegisterFirstAvailable(context.Context, []string, NewName) (string, error)
+		TakenNames(context.Context, []string) ([]string, error)
+		RegisterReservedName(context.Context, NewName, string) error
+		ReserveName(context.Context, string, string, string, time.Duration) (time.Time, error)
+		ReleaseReservation(context.Context, string) (bool, error)
+		PurgeExpiredReservations(context.Context) (int64, error)
+		PurgeExpiredRedirects(context.Context) (int64, error)
+		RenewName(context.Context, string, string) (time.Time, error)
+		ReleaseExpiredNames(context.Context) (int64, error)
+		CreateTransfer(context.Context, *Transfer, time.Duration) error
+		LookupTransfer(context.Context, int) (*Transfer, error)
+		AcceptTransfer(context.Context, int, string) (*Transfer, error)
+		CancelTransfer(context.Context, int, string) error
+		ExpireTransfers(context.Context) (int64, error)
+		ListAliasEvents(context.Context, string) ([]AliasEvent, error)
+		ExportNames(context.Context, func(ExportedName) error) error
+		RestoreNames(context.Context, []ExportedName) error
+		ListPolicyEntries(context.Context) ([]PolicyEntry, error)
+		AddPolicyEntry(context.Context, *PolicyEntry) error
+		DeletePolicyEntry(context.Context, int) (bool, error)
+		CreateAPIKey(context.Context, *APIKey, string) error
+		ListAPIKeys(context.Context) ([]APIKey, error)
+		RevokeAPIKey(context.Context, int) (bool, error)
+		UseAPIKey(context.Context, string) (*APIKey, error)
+		LookupTextRecords(context.Context, string) (map[string]string, error)
+		SetTextRecords(context.Context, string, map[string]string) error
+		CreateSIWEChallenge(context.Context, SIWEChallenge) error
+		ConsumeSIWEChallenge(context.Context, string, string, string) (string, []byte, error)
+		PurgeExpiredSIWEChallenges(context.Context) (int64, error)
+		ClaimIdempotencyKey(context.Context, string, string, string, time.Duration, time.Duration) (*IdempotencyRecord, error)
+		CompleteIdempotencyKey(context.Context, string, string, int, []byte) error
+		ReleaseIdempotencyKey(context.Context, string, string) error
+		PurgeIdempotencyKeys(context.Context, time.Duration) (int64, error)
 		Close()
 	}
+
+	// NameRecord separates the owner, who controls the name, from the address it resolves to.
+	NameRecord struct {
+		Name      string     `json:"name"`
+		Address   string     `json:"address"`
+		Owner     string     `json:"owner"`
+		Kind      string     `json:"kind"`
+		Primary   bool       `json:"primary"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+		// Expired names are in their grace period, they still resolve until released.
+		Expired   bool      `json:"expired"`
+		CreatedAt time.Time `json:"createdAt"`
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+
+	// NewName is a registration, Tenant is the service that registered the name.
+	NewName struct {
+		Name    string
+		Address string
+		Kind    string
+		Texts   map[string]string
+		Tenant  string
+	}
+
+	// NameFilter selects names for ListNames, zero values do not filter.
+	NameFilter struct {
+		Prefix        string
+		Contains      string
+		CreatedAfter  *time.Time
+		CreatedBefore *time.Time
+		UpdatedAfter  *time.Time
+		UpdatedBefore *time.Time
+		Active        *bool
+		Tenant        string
+		Kind          string
+		// Sort is one of the NameSort constants, names sort by name by default.
+		Sort       string
+		Descending bool
+		After      *NameCursor
+		Limit      int
+	}
+
+	// NameCursor is the sort value and ID of the last name of the previous page.
+	NameCursor struct {
+		Value string `json:"v"`
+		ID    int    `json:"id"`
+	}
+
+	ListedName struct {
+		ID        int        `json:"id"`
+		Name      string     `json:"name"`
+		Address   string     `json:"address"`
+		Owner     string     `json:"owner"`
+		Kind      string     `json:"kind"`
+		Tenant    string     `json:"tenant"`
+		Primary   bool       `json:"primary"`
+		Active    bool       `json:"active"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+		CreatedAt time.Time  `json:"createdAt"`
+		UpdatedAt time.Time  `json:"updatedAt"`
+	}
+
+	AddressName struct {
+		Name      string    `json:"name"`
+		Owner     string    `json:"owner"`
+		Kind      string    `json:"kind"`
+		Primary   bool      `json:"primary"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+
+	APIKey struct {
+		ID         int        `json:"id"`
+		Name       string     `json:"name"`
+		Scopes     []string   `json:"scopes"`
+		Active     bool       `json:"active"`
+		CreatedAt  time.Time  `json:"createdAt"`
+		ExpiresAt  *time.Time `json:"expiresAt"`
+		LastUsedAt *time.Time `json:"lastUsedAt"`
+	}
+
+	// SIWEChallenge is a pending self-service action, Signer is the checksummed address that has to sign the
+	// message carrying Statement. It can be consumed for TTL.
+	SIWEChallenge struct {
+		Nonce     string
+		Name      string
+		Signer    string
+		Statement string
+		Action    []byte
+		TTL       time.Duration
+	}
+
+	// IdempotencyRecord is a previously seen request, a zero StatusCode means it is still in flight.
+	IdempotencyRecord struct {
+		RequestHash string
+		StatusCode  int
+		Response    []byte
+	}
+
+	// Transfer moves a name to ToAddress, which becomes its owner and resolved address once accepted.
+	Transfer struct {
+		ID          int        `json:"id"`
+		Name        string     `json:"name"`
+		FromOwner   string     `json:"fromOwner"`
+		ToAddress   string     `json:"toAddress"`
+		Status      string     `json:"status"`
+		InitiatedBy string     `json:"initiatedBy"`
+		CreatedAt   time.Time  `json:"createdAt"`
+		ExpiresAt   time.Time  `json:"expiresAt"`
+		CompletedAt *time.Time `json:"completedAt"`
+	}
+
+	AliasEvent struct {
+		Event     string         `json:"event"`
+		Data      map[string]any `json:"data"`
+		Actor     string         `json:"actor"`
+		CreatedAt time.Time      `json:"createdAt"`
+	}
+
+	// ExportedName is a name with its text records as exported, it restores into any Store.
+	ExportedName struct {
+		Name      string            `json:"name"`
+		Address   string            `json:"address"`
+		Owner     string            `json:"owner"`
+		Kind      string            `json:"kind"`
+		Tenant    string            `json:"tenant"`
+		Primary   bool              `json:"primary"`
+		Texts     map[string]string `json:"texts"`
+		ExpiresAt *time.Time        `json:"expiresAt"`
+		CreatedAt time.Time         `json:"createdAt"`
+		UpdatedAt time.Time         `json:"updatedAt"`
+	}
+
+	// PolicyEntry is a runtime managed name policy item, List is one of reserved, blocked or regex.
+	PolicyEntry struct {
+		ID          int       `json:"id"`
+		List        string    `json:"list"`
+		Value       string    `json:"value"`
+		Description string    `json:"description"`
+		CreatedAt   time.Time `json:"createdAt"`
+	}
 )
@@ -0,0 +1,108 @@
+// Package sweeper periodically cleans up time bound state in the store.
+package sweeper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/grassrootseconomics/ens-offchain-resolver/internal/store"
+)
+
+type (
+	SweeperOpts struct {
+		Logg     *slog.Logger
+		Store    store.Store
+		Interval time.Duration
+	}
+
+	Sweeper struct {
+		logg     *slog.Logger
+		interval time.Duration
+		jobs     []job
+		stop     chan struct{}
+		done     chan struct{}
+	}
+
+	job struct {
+		name string
+		run  func(context.Context) (int64, error)
+	}
+)
+
+const (
+	defaultInterval = time.Minute
+	jobTimeout      = 30 * time.Second
+)
+
+func New(o SweeperOpts) *Sweeper {
+	interval := o.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	return &Sweeper{
+		logg:     o.Logg,
+		interval: interval,
+		jobs: []job{
+			{name: "expired reservations", run: o.Store.PurgeExpiredReservations},
+			{name: "expired rename redirects", run: o.Store.PurgeExpiredRedirects},
+			{name: "expired names", run: o.Store.ReleaseExpiredNames},
+			{name: "expired transfers", run: o.Store.ExpireTransfers},
+			{name: "expired SIWE challenges", run: o.Store.PurgeExpiredSIWEChallenges},
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// AddJob registers an additional job, it must be called before Start.
+func (s *Sweeper) AddJob(name string, run func(context.Context) (int64, error)) {
+	s.jobs = append(s.jobs, job{name: name, run: run})
+}
+
+// Start blocks, running every job once per interval until Stop is called.
+func (s *Sweeper) Start() {
+	defer close(s.done)
+	s.logg.Info("sweeper starting", "interval", s.interval)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.sweep()
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sweeper) Stop(ctx context.Context) error {
+	close(s.stop)
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Sweeper) sweep() {
+	for _, j := range s.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+		n, err := j.run(ctx)
+		cancel()
+
+		if err != nil {
+			s.logg.Error("sweeper job failed", "job", j.name, "error", err)
+			continue
+		}
+		if n > 0 {
+			s.logg.Debug("sweeper job done", "job", j.name, "affected", n)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/kamikazechaser/common/logg"
@@ -12,6 +13,18 @@ import (
 	"github.com/knadh/koanf/v2"
 )
 
+// listConfigKeys are split on whitespace when overridden from env vars.
+var listConfigKeys = []string{
+	"api.cors",
+	"api.jwt_issuers",
+	"api.autochoose_strategies",
+	"ratelimit.trusted_proxies",
+	"policy.reserved",
+	"policy.blocked",
+	"policy.blocklists",
+	"chain.eth_rpc_urls",
+}
+
 func InitLogger() *slog.Logger {
 	loggOpts := logg.LoggOpts{
 		FormatType: logg.Logfmt,
@@ -43,7 +56,7 @@ func InitConfig(lo *slog.Logger, confFilePath string) *koanf.Koanf {
 
 	if err := ko.Load(env.ProviderWithValue("RESOLVER_", ".", func(s string, v string) (string, interface{}) {
 		key := strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(s, "RESOLVER_")), "__", ".")
-		if key == "api.cors" {
+		if slices.Contains(listConfigKeys, key) {
 			return key, strings.Fields(v)
 		}
 		return key, v
@@ -54,3 +67,16 @@ func InitConfig(lo *slog.Logger, confFilePath string) *koanf.Koanf {
 
 	return ko
 }
+
+// RPCURLs returns the Ethereum RPC endpoints in the order they are tried. The deprecated single chain.eth_rpc_url
+// is still honoured and tried first, so that deployments overriding it keep using their endpoint.
+func RPCURLs(lo *slog.Logger, ko *koanf.Koanf) []string {
+	urls := ko.Strings("chain.eth_rpc_urls")
+
+	if url := ko.String("chain.eth_rpc_url"); url != "" {
+		lo.Warn("chain.eth_rpc_url (RESOLVER_CHAIN__ETH_RPC_URL) is deprecated, list the endpoints in chain.eth_rpc_urls instead")
+		urls = append([]string{url}, slices.DeleteFunc(urls, func(u string) bool { return u == url })...)
+	}
+
+	return urls
+}
@@ -0,0 +1,18 @@
+-- Text records attached to a name
+CREATE TABLE IF NOT EXISTS text_record (
+    alias_id INT NOT NULL REFERENCES alias(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (alias_id, key)
+);
+
+-- Pending self-service actions awaiting a SIWE signature
+CREATE TABLE IF NOT EXISTS siwe_challenge (
+    nonce TEXT PRIMARY KEY,
+    primary_name TEXT NOT NULL,
+    action JSONB NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
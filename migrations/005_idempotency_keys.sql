@@ -0,0 +1,10 @@
+-- First responses of register/upsert calls replayed for retried requests
+CREATE TABLE IF NOT EXISTS idempotency_key (
+    subject TEXT NOT NULL,
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INT,
+    response BYTEA,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (subject, key)
+);
@@ -0,0 +1,9 @@
+-- Short lived holds on names picked during onboarding
+CREATE TABLE IF NOT EXISTS name_reservation (
+    primary_name TEXT PRIMARY KEY,
+    token TEXT UNIQUE NOT NULL,
+    subject TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS name_reservation_expires_at_idx ON name_reservation(expires_at);
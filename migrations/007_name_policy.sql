@@ -0,0 +1,9 @@
+-- Runtime managed reserved names, blocked words and regex rules
+CREATE TABLE IF NOT EXISTS name_policy_entry (
+    id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    list TEXT NOT NULL CHECK (list IN ('reserved', 'blocked', 'regex')),
+    value TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (list, value)
+);
@@ -0,0 +1,5 @@
+-- Allow several active names per address, exactly one of them is the primary used for reverse resolution
+ALTER TABLE alias DROP CONSTRAINT IF EXISTS unique_blockchain_address;
+ALTER TABLE alias ADD COLUMN IF NOT EXISTS is_primary BOOLEAN NOT NULL DEFAULT false;
+UPDATE alias SET is_primary = true WHERE active = true;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_primary_name_per_address ON alias(blockchain_address) WHERE is_primary = true AND active = true;
@@ -0,0 +1,5 @@
+-- Separate who controls a name from the address it resolves to
+ALTER TABLE alias ADD COLUMN IF NOT EXISTS owner TEXT;
+UPDATE alias SET owner = blockchain_address WHERE owner IS NULL;
+ALTER TABLE alias ALTER COLUMN owner SET NOT NULL;
+CREATE INDEX IF NOT EXISTS owner_idx ON alias(owner);
@@ -0,0 +1,4 @@
+-- Categories of names, each kind lives under its own namespace
+ALTER TABLE alias ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'user'
+    CHECK (kind IN ('user', 'voucher', 'pool', 'organisation'));
+CREATE INDEX IF NOT EXISTS kind_idx ON alias(kind);
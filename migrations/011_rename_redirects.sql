@@ -0,0 +1,10 @@
+-- Old names keep resolving to the renamed alias for a grace period and cannot be registered meanwhile
+CREATE TABLE IF NOT EXISTS alias_redirect (
+    old_name TEXT PRIMARY KEY,
+    alias_id INT NOT NULL REFERENCES alias(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS alias_redirect_expires_at_idx ON alias_redirect(expires_at);
+
+ALTER TABLE alias ADD COLUMN IF NOT EXISTS renamed_at TIMESTAMP;
@@ -0,0 +1,6 @@
+-- Optional leases, expired names are deactivated after a grace period and can be registered again
+ALTER TABLE alias ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS alias_expires_at_idx ON alias(expires_at) WHERE active = true AND expires_at IS NOT NULL;
+
+ALTER TABLE alias DROP CONSTRAINT IF EXISTS alias_primary_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_active_primary_name ON alias(primary_name) WHERE active = true;
@@ -0,0 +1,25 @@
+-- Two step transfers of a name to another address
+CREATE TABLE IF NOT EXISTS name_transfer (
+    id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    alias_id INT NOT NULL REFERENCES alias(id) ON DELETE CASCADE,
+    from_owner TEXT NOT NULL,
+    to_address TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'cancelled', 'expired')),
+    initiated_by TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS one_pending_transfer_per_alias ON name_transfer(alias_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS name_transfer_expires_at_idx ON name_transfer(expires_at) WHERE status = 'pending';
+
+-- History of changes to a name
+CREATE TABLE IF NOT EXISTS alias_event (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    alias_id INT NOT NULL REFERENCES alias(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    actor TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS alias_event_alias_id_idx ON alias_event(alias_id, id);
@@ -0,0 +1,12 @@
+-- The service that registered a name, the JWT subject or API key, unknown for older names
+ALTER TABLE alias ADD COLUMN IF NOT EXISTS tenant TEXT;
+CREATE INDEX IF NOT EXISTS alias_tenant_idx ON alias(tenant);
+
+-- Name search, prefix matches use the pattern index and substring matches the trigram index
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS alias_primary_name_pattern_idx ON alias(primary_name text_pattern_ops);
+CREATE INDEX IF NOT EXISTS alias_primary_name_trgm_idx ON alias USING gin (primary_name gin_trgm_ops);
+
+-- Keyset pagination by creation and update time
+CREATE INDEX IF NOT EXISTS alias_created_at_idx ON alias(created_at, id);
+CREATE INDEX IF NOT EXISTS alias_updated_at_idx ON alias(updated_at, id);
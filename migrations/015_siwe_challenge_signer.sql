@@ -0,0 +1,6 @@
+-- The address a challenge was issued to and the statement it was issued with, a signed message has to match
+-- both before the challenge is consumed. Challenges issued before are left unusable and expire.
+ALTER TABLE siwe_challenge ADD COLUMN IF NOT EXISTS signer TEXT NOT NULL DEFAULT '';
+ALTER TABLE siwe_challenge ADD COLUMN IF NOT EXISTS statement TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS siwe_challenge_expires_at_idx ON siwe_challenge(expires_at);
@@ -0,0 +1,2 @@
+-- Keys older than the idempotency window are purged by the sweeper
+CREATE INDEX IF NOT EXISTS idempotency_key_created_at_idx ON idempotency_key(created_at);
@@ -0,0 +1,281 @@
+package ens
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/lmittmann/w3"
+)
+
+// Avatar is an avatar image ready to be served.
+type Avatar struct {
+	ContentType string
+	Data        []byte
+}
+
+const (
+	defaultIPFSGateway   = "https://ipfs.io/ipfs/"
+	defaultMaxAvatarSize = 2 << 20
+	// maxMetadataSize bounds NFT metadata documents, which only have to carry the image URI.
+	maxMetadataSize = 256 << 10
+)
+
+var (
+	// ErrUnsupportedAvatar is returned for avatar records that are not an image or an NFT on Ethereum mainnet.
+	ErrUnsupportedAvatar = errors.New("unsupported avatar record")
+	// ErrAvatarNotOwned is returned for NFT avatars that are not held by the address of the name, as per ENSIP-12
+	// they must not be shown.
+	ErrAvatarNotOwned = errors.New("avatar NFT is not owned by the name")
+	ErrAvatarTooLarge = errors.New("avatar is too large")
+
+	// https://docs.ens.domains/ensip/12
+	nftAvatarPattern = regexp.MustCompile(`^eip155:1/(erc721|erc1155):(0x[0-9a-fA-F]{40})/([0-9]+)$`)
+
+	ownerOfFunc   = w3.MustNewFunc("ownerOf(uint256)", "address")
+	tokenURIFunc  = w3.MustNewFunc("tokenURI(uint256)", "string")
+	balanceOfFunc = w3.MustNewFunc("balanceOf(address,uint256)", "uint256")
+	uriFunc       = w3.MustNewFunc("uri(uint256)", "string")
+)
+
+// ResolveAvatar returns the image of an ENSIP-12 avatar record of a name resolving to owner. Records can be
+// https, ipfs and data URIs or NFTs on Ethereum mainnet, whose ownership is verified. Images are kept for the
+// avatar cache TTL, the ownership of NFTs is checked again once the name cache expires.
+func (e *ENS) ResolveAvatar(ctx context.Context, record string, owner common.Address) (*Avatar, error) {
+	imageURI, err := cached(e.cache, "avatar:"+owner.Hex()+":"+record, func() (string, error) {
+		return e.avatarImageURI(ctx, record, owner)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Data URIs carry the image, they are only decoded.
+	fetched := !strings.HasPrefix(imageURI, "data:")
+	if fetched {
+		if avatar, ok := e.avatarCache.get(imageURI, time.Now()); ok {
+			return avatar, nil
+		}
+	}
+
+	data, contentType, err := e.fetchURI(ctx, imageURI, e.maxAvatarSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parameters such as the utf8 of data:image/svg+xml;utf8 are often malformed, only the media type matters.
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if (err != nil && !errors.Is(err, mime.ErrInvalidMediaParameter)) || !strings.HasPrefix(mediaType, "image/") {
+		return nil, fmt.Errorf("%w: content type %q", ErrUnsupportedAvatar, contentType)
+	}
+
+	avatar := &Avatar{
+		ContentType: mediaType,
+		Data:        data,
+	}
+	if fetched {
+		e.avatarCache.set(imageURI, avatar, time.Now())
+	}
+
+	return avatar, nil
+}
+
+// avatarImageURI dereferences NFT avatar records to the image of their metadata, other records are images.
+func (e *ENS) avatarImageURI(ctx context.Context, record string, owner common.Address) (string, error) {
+	match := nftAvatarPattern.FindStringSubmatch(strings.ToLower(record))
+	if match == nil {
+		if strings.HasPrefix(strings.ToLower(record), "eip155:") {
+			return "", ErrUnsupportedAvatar
+		}
+		return record, nil
+	}
+
+	contract := common.HexToAddress(match[2])
+	tokenID, ok := new(big.Int).SetString(match[3], 10)
+	if !ok {
+		return "", ErrUnsupportedAvatar
+	}
+
+	tokenURI, err := call(ctx, e, func(backend bind.ContractBackend) (string, error) {
+		if match[1] == "erc721" {
+			return erc721TokenURI(ctx, backend, contract, tokenID, owner)
+		}
+		return erc1155TokenURI(ctx, backend, contract, tokenID, owner)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	metadata, _, err := e.fetchURI(ctx, tokenURI, maxMetadataSize)
+	if err != nil {
+		return "", err
+	}
+
+	var nft struct {
+		Image     string `json:"image"`
+		ImageURL  string `json:"image_url"`
+		ImageData string `json:"image_data"`
+	}
+	if err := json.Unmarshal(metadata, &nft); err != nil {
+		return "", fmt.Errorf("%w: invalid NFT metadata: %v", ErrUnsupportedAvatar, err)
+	}
+
+	switch {
+	case nft.Image != "":
+		return nft.Image, nil
+	case nft.ImageURL != "":
+		return nft.ImageURL, nil
+	case nft.ImageData != "":
+		return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(nft.ImageData)), nil
+	}
+
+	return "", fmt.Errorf("%w: NFT metadata has no image", ErrUnsupportedAvatar)
+}
+
+func erc721TokenURI(ctx context.Context, backend bind.ContractBackend, contract common.Address, tokenID *big.Int, owner common.Address) (string, error) {
+	var holder common.Address
+	if err := contractCall(ctx, backend, contract, ownerOfFunc, []any{tokenID}, &holder); err != nil {
+		return "", err
+	}
+	if holder != owner {
+		return "", ErrAvatarNotOwned
+	}
+
+	var tokenURI string
+	if err := contractCall(ctx, backend, contract, tokenURIFunc, []any{tokenID}, &tokenURI); err != nil {
+		return "", err
+	}
+
+	return tokenURI, nil
+}
+
+func erc1155TokenURI(ctx context.Context, backend bind.ContractBackend, contract common.Address, tokenID *big.Int, owner common.Address) (string, error) {
+	var balance *big.Int
+	if err := contractCall(ctx, backend, contract, balanceOfFunc, []any{owner, tokenID}, &balance); err != nil {
+		return "", err
+	}
+	if balance.Sign() == 0 {
+		return "", ErrAvatarNotOwned
+	}
+
+	var tokenURI string
+	if err := contractCall(ctx, backend, contract, uriFunc, []any{tokenID}, &tokenURI); err != nil {
+		return "", err
+	}
+
+	// https://eips.ethereum.org/EIPS/eip-1155#metadata
+	return strings.ReplaceAll(tokenURI, "{id}", fmt.Sprintf("%064x", tokenID)), nil
+}
+
+func contractCall(ctx context.Context, backend bind.ContractBackend, contract common.Address, fn *w3.Func, args []any, returns ...any) error {
+	input, err := fn.EncodeArgs(args...)
+	if err != nil {
+		return err
+	}
+
+	output, err := backend.CallContract(ctx, ethereum.CallMsg{
+		To:   &contract,
+		Data: input,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	return fn.DecodeReturns(output, returns...)
+}
+
+// fetchURI returns the content of a https, ipfs or data URI and its content type. IPFS content is fetched
+// through the configured gateway.
+func (e *ENS) fetchURI(ctx context.Context, uri string, maxSize int64) ([]byte, string, error) {
+	switch {
+	case strings.HasPrefix(uri, "data:"):
+		return parseDataURI(uri, maxSize)
+	case strings.HasPrefix(uri, "ipfs://"):
+		uri = e.ipfsGateway + strings.TrimPrefix(strings.TrimPrefix(uri, "ipfs://"), "ipfs/")
+	case strings.HasPrefix(uri, "https://"):
+	default:
+		return nil, "", fmt.Errorf("%w: unsupported URI scheme", ErrUnsupportedAvatar)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, "", fmt.Errorf("%w: fetch %s: status %d", ErrUpstream, uri, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetch %s: status %d", uri, resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, "", ErrAvatarTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", ErrAvatarTooLarge
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// parseDataURI decodes a RFC 2397 data URI.
+func parseDataURI(uri string, maxSize int64) ([]byte, string, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return nil, "", fmt.Errorf("%w: invalid data URI", ErrUnsupportedAvatar)
+	}
+
+	contentType, isBase64 := strings.CutSuffix(header, ";base64")
+	if contentType == "" {
+		contentType = "text/plain;charset=US-ASCII"
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if isBase64 {
+		if int64(base64.StdEncoding.DecodedLen(len(payload))) > maxSize+2 {
+			return nil, "", ErrAvatarTooLarge
+		}
+		data, err = base64.StdEncoding.DecodeString(payload)
+	} else {
+		var s string
+		s, err = url.PathUnescape(payload)
+		data = []byte(s)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: invalid data URI: %v", ErrUnsupportedAvatar, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", ErrAvatarTooLarge
+	}
+
+	return data, contentType, nil
+}
@@ -0,0 +1,90 @@
+package ens
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseDataURI(t *testing.T) {
+	tests := []struct {
+		uri         string
+		data        string
+		contentType string
+		err         error
+	}{
+		{uri: "data:image/png;base64,iVBORw0K", data: "\x89PNG\r\n", contentType: "image/png"},
+		{uri: "data:image/svg+xml;utf8,%3Csvg%2F%3E", data: "<svg/>", contentType: "image/svg+xml;utf8"},
+		{uri: "data:,hello", data: "hello", contentType: "text/plain;charset=US-ASCII"},
+		{uri: "data:image/png;base64", err: ErrUnsupportedAvatar},
+		{uri: "data:image/png;base64,!!!", err: ErrUnsupportedAvatar},
+		{uri: "data:image/png;base64,QUFBQUFBQUFBQUFBQUFBQQ==", err: ErrAvatarTooLarge},
+	}
+
+	for _, tt := range tests {
+		data, contentType, err := parseDataURI(tt.uri, 8)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("parseDataURI(%q) error = %v, want %v", tt.uri, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDataURI(%q) error: %v", tt.uri, err)
+			continue
+		}
+		if string(data) != tt.data || contentType != tt.contentType {
+			t.Errorf("parseDataURI(%q) = %q, %q, want %q, %q", tt.uri, data, contentType, tt.data, tt.contentType)
+		}
+	}
+}
+
+func TestResolveAvatarCachesImages(t *testing.T) {
+	fetches := make(map[string]int)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches[r.URL.Path]++
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	e := &ENS{
+		timeout:       time.Second,
+		cache:         newCache(time.Minute, time.Minute),
+		httpClient:    srv.Client(),
+		maxAvatarSize: 1024,
+		// Room for one image only.
+		avatarCache: newImageCache(time.Minute, 15),
+	}
+
+	resolve := func(path string) {
+		t.Helper()
+		avatar, err := e.ResolveAvatar(context.Background(), srv.URL+path, common.Address{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if avatar.ContentType != "image/png" || string(avatar.Data) != "0123456789" {
+			t.Fatalf("avatar = %q, %q", avatar.ContentType, avatar.Data)
+		}
+	}
+
+	resolve("/a.png")
+	resolve("/a.png")
+	if fetches["/a.png"] != 1 {
+		t.Errorf("fetched /a.png %d times, want the second request cached", fetches["/a.png"])
+	}
+
+	resolve("/b.png")
+	resolve("/a.png")
+	if fetches["/a.png"] != 2 {
+		t.Errorf("fetched /a.png %d times, want it dropped for /b.png", fetches["/a.png"])
+	}
+	if e.avatarCache.size > e.avatarCache.maxSize {
+		t.Errorf("cache size = %d, want at most %d", e.avatarCache.size, e.avatarCache.maxSize)
+	}
+}
@@ -0,0 +1,168 @@
+package ens
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+type (
+	// cache keeps upstream results for a while, including names and addresses that did not resolve.
+	cache struct {
+		mu          sync.Mutex
+		ttl         time.Duration
+		negativeTTL time.Duration
+		entries     map[string]cacheEntry
+		lastSweep   time.Time
+	}
+
+	cacheEntry struct {
+		value     any
+		err       error
+		expiresAt time.Time
+	}
+)
+
+func newCache(ttl time.Duration, negativeTTL time.Duration) *cache {
+	return &cache{
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]cacheEntry),
+		lastSweep:   time.Now(),
+	}
+}
+
+func (c *cache) get(key string, now time.Time) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		return cacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+func (c *cache) set(key string, value any, err error, now time.Time) {
+	ttl := c.ttl
+	if err != nil {
+		ttl = c.negativeTTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop expired entries so that the map does not grow with every name ever asked for.
+	if now.Sub(c.lastSweep) > c.ttl {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	c.entries[key] = cacheEntry{
+		value:     value,
+		err:       err,
+		expiresAt: now.Add(ttl),
+	}
+}
+
+// cached returns the cached result of key or stores the result of fn. Only answers are cached, including names
+// and records that do not resolve. Failures to reach an endpoint or gateway say nothing about the name.
+func cached[T any](c *cache, key string, fn func() (T, error)) (T, error) {
+	if entry, ok := c.get(key, time.Now()); ok {
+		v, _ := entry.value.(T)
+		return v, entry.err
+	}
+
+	v, err := fn()
+	if !transient(err) {
+		c.set(key, v, err, time.Now())
+	}
+
+	return v, err
+}
+
+// transient reports whether err is a failure to get an answer rather than an answer.
+func transient(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, ErrUnavailable) ||
+		errors.Is(err, ErrUpstream) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) ||
+		errors.As(err, &netErr)
+}
+
+type (
+	// imageCache keeps fetched avatar images for a while, bounded by their total size. All images are kept for
+	// the same time, so the oldest ones are the first to expire and the first to be dropped when it is full.
+	imageCache struct {
+		mu      sync.Mutex
+		ttl     time.Duration
+		maxSize int64
+		size    int64
+		entries map[string]imageEntry
+		order   []string
+	}
+
+	imageEntry struct {
+		avatar    *Avatar
+		expiresAt time.Time
+	}
+)
+
+func newImageCache(ttl time.Duration, maxSize int64) *imageCache {
+	return &imageCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[string]imageEntry),
+	}
+}
+
+func (c *imageCache) get(uri string, now time.Time) (*Avatar, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[uri]
+	if !ok || now.After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.avatar, true
+}
+
+func (c *imageCache) set(uri string, avatar *Avatar, now time.Time) {
+	size := int64(len(avatar.Data))
+	if size > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[uri]; ok {
+		c.remove(uri)
+	}
+	for len(c.order) > 0 && (c.size+size > c.maxSize || now.After(c.entries[c.order[0]].expiresAt)) {
+		c.remove(c.order[0])
+	}
+
+	c.entries[uri] = imageEntry{
+		avatar:    avatar,
+		expiresAt: now.Add(c.ttl),
+	}
+	c.order = append(c.order, uri)
+	c.size += size
+}
+
+func (c *imageCache) remove(uri string) {
+	c.size -= int64(len(c.entries[uri].avatar.Data))
+	delete(c.entries, uri)
+	c.order = slices.DeleteFunc(c.order, func(k string) bool { return k == uri })
+}
@@ -0,0 +1,78 @@
+package ens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCached(t *testing.T) {
+	c := newCache(time.Minute, time.Minute)
+	errNoResolution := errors.New("no resolution")
+
+	calls := 0
+	resolve := func(v string, err error) func() (string, error) {
+		return func() (string, error) {
+			calls++
+			return v, err
+		}
+	}
+
+	for range 2 {
+		if v, err := cached(c, "addr:alice.eth", resolve("0x1", nil)); v != "0x1" || err != nil {
+			t.Fatalf("cached() = %q, %v, want 0x1", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("resolved %d times, want the second call cached", calls)
+	}
+
+	calls = 0
+	for range 2 {
+		if _, err := cached(c, "addr:unknown.eth", resolve("", errNoResolution)); !errors.Is(err, errNoResolution) {
+			t.Fatalf("cached() error = %v, want %v", err, errNoResolution)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("resolved %d times, want negative results cached", calls)
+	}
+
+	for _, transientErr := range []error{
+		fmt.Errorf("%w: timeout", ErrUnavailable),
+		fmt.Errorf("%w: fetch https://example.com/nft/1: status 503", ErrUpstream),
+		fmt.Errorf("%w: dial tcp: i/o timeout", ErrUpstream),
+		context.DeadlineExceeded,
+		&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+	} {
+		calls = 0
+		for range 2 {
+			if _, err := cached(c, "avatar:bob.eth", resolve("", transientErr)); !errors.Is(err, transientErr) {
+				t.Fatalf("cached() error = %v, want %v", err, transientErr)
+			}
+		}
+		if calls != 2 {
+			t.Errorf("resolved %d times, want %v not cached", calls, transientErr)
+		}
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := newCache(time.Minute, time.Second)
+	now := time.Now()
+
+	c.set("addr:alice.eth", "0x1", nil, now)
+	c.set("addr:unknown.eth", "", errors.New("no resolution"), now)
+
+	if _, ok := c.get("addr:alice.eth", now.Add(30*time.Second)); !ok {
+		t.Error("entry expired before its TTL")
+	}
+	if _, ok := c.get("addr:unknown.eth", now.Add(30*time.Second)); ok {
+		t.Error("negative entry outlived the negative TTL")
+	}
+	if _, ok := c.get("addr:alice.eth", now.Add(2*time.Minute)); ok {
+		t.Error("entry outlived its TTL")
+	}
+}
@@ -1,47 +1,286 @@
 package ens
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/binary"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	goens "github.com/grassrootseconomics/go-ens/v3"
+	"github.com/lmittmann/w3"
 )
 
-type ENS struct {
-	signingKey *ecdsa.PrivateKey
-	ethClient  *ethclient.Client
+type (
+	ProviderOpts struct {
+		Logg       *slog.Logger
+		SigningKey *ecdsa.PrivateKey
+		// RPCURLs are tried in order, an endpoint that fails to answer is tried last until it passes a health
+		// check.
+		RPCURLs []string
+		// Timeout bounds every attempt at an endpoint and every CCIP-Read gateway request.
+		Timeout time.Duration
+		// CacheTTL is how long resolved names and addresses are cached, NegativeCacheTTL is how long those that
+		// did not resolve are.
+		CacheTTL         time.Duration
+		NegativeCacheTTL time.Duration
+		// UniversalResolver overrides DefaultUniversalResolver, e.g. on testnets.
+		UniversalResolver string
+		// IPFSGateway is the URL prefix ipfs:// avatars and NFT metadata are fetched through, defaults to ipfs.io.
+		IPFSGateway string
+		// MaxAvatarSize caps avatar images in bytes, defaults to 2MB.
+		MaxAvatarSize int64
+		// AvatarCacheTTL is how long fetched avatar images are kept, defaults to 1h. AvatarCacheSize bounds
+		// the total size of kept images in bytes, defaults to 64MB.
+		AvatarCacheTTL  time.Duration
+		AvatarCacheSize int64
+	}
+
+	ENS struct {
+		logg       *slog.Logger
+		signingKey *ecdsa.PrivateKey
+		timeout    time.Duration
+		cache      *cache
+
+		universalResolver common.Address
+		httpClient        *http.Client
+		ipfsGateway       string
+		maxAvatarSize     int64
+		avatarCache       *imageCache
+
+		mu        sync.Mutex
+		endpoints []*endpoint
+	}
+)
+
+const (
+	ttl = time.Minute * 5
+
+	defaultTimeout          = 5 * time.Second
+	defaultCacheTTL         = 5 * time.Minute
+	defaultNegativeCacheTTL = time.Minute
+	defaultAvatarCacheTTL   = time.Hour
+	defaultAvatarCacheSize  = 64 << 20
+)
+
+var (
+	eip191Prefix = []byte{0x19, 0x00}
+
+	// https://eips.ethereum.org/EIPS/eip-1271
+	isValidSignatureFunc = w3.MustNewFunc("isValidSignature(bytes32,bytes)", "bytes4")
+	eip1271MagicValue    = [4]byte{0x16, 0x26, 0xba, 0x7e}
+)
+
+func NewProvider(o ProviderOpts) (*ENS, error) {
+	if len(o.RPCURLs) == 0 {
+		return nil, fmt.Errorf("at least one RPC URL is required")
+	}
+
+	e := &ENS{
+		logg:       o.Logg,
+		signingKey: o.SigningKey,
+		timeout:    o.Timeout,
+		cache:      newCache(o.CacheTTL, o.NegativeCacheTTL),
+
+		universalResolver: common.HexToAddress(DefaultUniversalResolver),
+		httpClient:        newGatewayClient(),
+		ipfsGateway:       o.IPFSGateway,
+		maxAvatarSize:     o.MaxAvatarSize,
+		avatarCache:       newImageCache(o.AvatarCacheTTL, o.AvatarCacheSize),
+	}
+	if e.ipfsGateway == "" {
+		e.ipfsGateway = defaultIPFSGateway
+	}
+	if e.maxAvatarSize <= 0 {
+		e.maxAvatarSize = defaultMaxAvatarSize
+	}
+	if o.UniversalResolver != "" {
+		if !common.IsHexAddress(o.UniversalResolver) {
+			return nil, fmt.Errorf("invalid universal resolver address %q", o.UniversalResolver)
+		}
+		e.universalResolver = common.HexToAddress(o.UniversalResolver)
+	}
+	if e.timeout <= 0 {
+		e.timeout = defaultTimeout
+	}
+	if e.cache.ttl <= 0 {
+		e.cache.ttl = defaultCacheTTL
+	}
+	if e.cache.negativeTTL <= 0 {
+		e.cache.negativeTTL = defaultNegativeCacheTTL
+	}
+	if e.avatarCache.ttl <= 0 {
+		e.avatarCache.ttl = defaultAvatarCacheTTL
+	}
+	if e.avatarCache.maxSize <= 0 {
+		e.avatarCache.maxSize = defaultAvatarCacheSize
+	}
+
+	for _, url := range o.RPCURLs {
+		ethClient, err := ethclient.Dial(url)
+		if err != nil {
+			return nil, err
+		}
+
+		e.endpoints = append(e.endpoints, &endpoint{
+			url:     url,
+			client:  ethClient,
+			healthy: true,
+		})
+	}
+
+	return e, nil
 }
 
-const ttl = time.Minute * 5
+// ResolveName returns the Ethereum address of a name, names using CCIP-Read resolvers included.
+func (e *ENS) ResolveName(ctx context.Context, name string) (common.Address, error) {
+	if name == "" {
+		return common.Address{}, fmt.Errorf("name cannot be empty")
+	}
 
-var eip191Prefix = []byte{0x19, 0x00}
+	return cached(e.cache, "addr:"+name, func() (common.Address, error) {
+		return call(ctx, e, func(backend bind.ContractBackend) (common.Address, error) {
+			return e.resolveETHAddress(ctx, backend, name)
+		})
+	})
+}
 
-func NewProvider(signingKey *ecdsa.PrivateKey, ethRPCURL string) (*ENS, error) {
-	ethClient, err := ethclient.Dial(ethRPCURL)
+func (e *ENS) resolveETHAddress(ctx context.Context, backend bind.ContractBackend, name string) (common.Address, error) {
+	address, err := e.resolveAddress(ctx, backend, name, CoinTypeETH)
 	if err != nil {
-		return nil, err
+		return common.Address{}, err
+	}
+	if address == "" {
+		return common.Address{}, fmt.Errorf("name %s has no address", name)
 	}
 
-	return &ENS{
-		signingKey: signingKey,
-		ethClient:  ethClient,
-	}, nil
+	return common.HexToAddress(address), nil
 }
 
-func (e *ENS) ResolveName(name string) (common.Address, error) {
+// ReverseResolve returns the primary ENS name of an address. The name is only returned when it resolves back to
+// the address, since anyone can claim any name as the primary name of their address.
+func (e *ENS) ReverseResolve(ctx context.Context, address common.Address) (string, error) {
+	return cached(e.cache, "name:"+address.Hex(), func() (string, error) {
+		return call(ctx, e, func(backend bind.ContractBackend) (string, error) {
+			name, err := goens.ReverseResolve(backend, address)
+			if err != nil {
+				return "", err
+			}
+
+			resolved, err := e.resolveETHAddress(ctx, backend, name)
+			if err != nil {
+				return "", err
+			}
+			if resolved != address {
+				return "", fmt.Errorf("name %s does not resolve back to %s", name, address.Hex())
+			}
+
+			return name, nil
+		})
+	})
+}
+
+// Records are the records of a name as set on its resolver.
+type Records struct {
+	// Addresses maps coin types to addresses, EVM addresses in checksum form and others hex encoded.
+	Addresses   map[uint64]string
+	Contenthash []byte
+	Texts       map[string]string
+}
+
+// ResolveRecords reads the addresses for the given coin types, the content hash and the given text records of a
+// name through the Universal Resolver, names using CCIP-Read resolvers included. Records that are not set or
+// that the resolver does not support are left out, it fails when the name has no resolver.
+func (e *ENS) ResolveRecords(ctx context.Context, name string, coinTypes []uint64, textKeys []string) (*Records, error) {
 	if name == "" {
-		return common.Address{}, fmt.Errorf("name cannot be empty")
+		return nil, fmt.Errorf("name cannot be empty")
+	}
+
+	key := fmt.Sprintf("records:%s:%v:%s", name, coinTypes, strings.Join(textKeys, ","))
+	return cached(e.cache, key, func() (*Records, error) {
+		return call(ctx, e, func(backend bind.ContractBackend) (*Records, error) {
+			records := &Records{
+				Addresses: make(map[uint64]string),
+				Texts:     make(map[string]string),
+			}
+
+			// The first record tells whether the name has a resolver at all.
+			contenthash, err := e.resolveContenthash(ctx, backend, name)
+			if err != nil && !resolverUnsupported(err) {
+				return nil, err
+			}
+			records.Contenthash = contenthash
+
+			for _, coinType := range coinTypes {
+				address, err := e.resolveAddress(ctx, backend, name, coinType)
+				if err != nil {
+					if resolverUnsupported(err) {
+						continue
+					}
+					return nil, err
+				}
+				if address != "" {
+					records.Addresses[coinType] = address
+				}
+			}
+
+			for _, key := range textKeys {
+				value, err := e.resolveText(ctx, backend, name, key)
+				if err != nil {
+					if resolverUnsupported(err) {
+						continue
+					}
+					return nil, err
+				}
+				if value != "" {
+					records.Texts[key] = value
+				}
+			}
+
+			return records, nil
+		})
+	})
+}
+
+// IsValidSignature asks a smart account whether sig is valid for hash as per EIP-1271.
+// Addresses without code are reported as invalid rather than as an error.
+func (e *ENS) IsValidSignature(ctx context.Context, account common.Address, hash common.Hash, sig []byte) (bool, error) {
+	input, err := isValidSignatureFunc.EncodeArgs(hash, sig)
+	if err != nil {
+		return false, err
+	}
+
+	output, err := call(ctx, e, func(backend bind.ContractBackend) ([]byte, error) {
+		return backend.CallContract(ctx, ethereum.CallMsg{
+			To:   &account,
+			Data: input,
+		}, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if len(output) == 0 {
+		return false, nil
+	}
+
+	var magicValue [4]byte
+	if err := isValidSignatureFunc.DecodeReturns(output, &magicValue); err != nil {
+		return false, nil
 	}
 
-	return goens.Resolve(e.ethClient, name)
+	return magicValue == eip1271MagicValue, nil
 }
 
 func (e *ENS) SignPayload(sender common.Address, request []byte, result []byte) (string, error) {
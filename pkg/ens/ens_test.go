@@ -0,0 +1,192 @@
+package ens
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	goens "github.com/grassrootseconomics/go-ens/v3"
+	"github.com/lmittmann/w3"
+)
+
+type (
+	// testChain is a JSON-RPC endpoint answering eth_call with the contracts of a test, calls to other
+	// addresses return no data.
+	testChain map[common.Address]func(input []byte) ([]byte, error)
+
+	// revert makes a test contract revert with data, e.g. a custom error.
+	revert []byte
+)
+
+var (
+	registryResolverFunc = w3.MustNewFunc("resolver(bytes32)", "address")
+	nameFunc             = w3.MustNewFunc("name(bytes32)", "string")
+
+	testResolver = common.HexToAddress("0x000000000000000000000000000000000000bEEF")
+)
+
+func (revert) Error() string {
+	return "execution reverted"
+}
+
+// newTestENS returns a provider whose only endpoint is chain.
+func newTestENS(t *testing.T, chain testChain) *ENS {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_getCode":
+			var address common.Address
+			_ = json.Unmarshal(req.Params[0], &address)
+			resp["result"] = "0x"
+			if _, ok := chain[address]; ok {
+				resp["result"] = "0x01"
+			}
+		case "eth_call":
+			var msg struct {
+				To    common.Address `json:"to"`
+				Input hexutil.Bytes  `json:"input"`
+				Data  hexutil.Bytes  `json:"data"`
+			}
+			_ = json.Unmarshal(req.Params[0], &msg)
+			if len(msg.Input) == 0 {
+				msg.Input = msg.Data
+			}
+
+			contract, ok := chain[msg.To]
+			if !ok {
+				resp["result"] = "0x"
+				break
+			}
+
+			output, err := contract(msg.Input)
+			var data revert
+			switch {
+			case errors.As(err, &data):
+				resp["error"] = map[string]any{"code": 3, "message": "execution reverted", "data": hexutil.Encode(data)}
+			case err != nil:
+				resp["error"] = map[string]any{"code": -32000, "message": err.Error()}
+			default:
+				resp["result"] = hexutil.Encode(output)
+			}
+		default:
+			resp["error"] = map[string]any{"code": -32601, "message": "method not found"}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	e, err := NewProvider(ProviderOpts{
+		Logg:    slog.New(slog.DiscardHandler),
+		RPCURLs: []string{srv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// withNames adds the ENS registry, a reverse resolver and a Universal Resolver to chain. Every name in
+// addresses resolves to its address and every address in primaryNames reverse resolves to its name.
+func withNames(t *testing.T, chain testChain, addresses map[string]common.Address, primaryNames map[common.Address]string) testChain {
+	t.Helper()
+
+	registry, err := goens.RegistryContractAddress(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain[registry] = func(input []byte) ([]byte, error) {
+		return registryResolverFunc.Returns.Pack(testResolver)
+	}
+
+	reverseNodes := make(map[common.Hash]string)
+	for address, name := range primaryNames {
+		node, err := goens.NameHash(fmt.Sprintf("%x.addr.reverse", address.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reverseNodes[node] = name
+	}
+	chain[testResolver] = func(input []byte) ([]byte, error) {
+		var node common.Hash
+		if err := nameFunc.DecodeArgs(input, &node); err != nil {
+			return nil, err
+		}
+		return nameFunc.Returns.Pack(reverseNodes[node])
+	}
+
+	nodes := make(map[common.Hash]common.Address)
+	for name, address := range addresses {
+		node, err := goens.NameHash(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes[node] = address
+	}
+	chain[common.HexToAddress(DefaultUniversalResolver)] = universalResolver(func(node common.Hash, data []byte) ([]byte, error) {
+		address, ok := nodes[node]
+		if !ok {
+			return nil, revert(w3.MustNewFunc("ResolverNotFound(bytes)", "").Selector[:])
+		}
+		return addrFunc.Returns.Pack(address)
+	})
+
+	return chain
+}
+
+// universalResolver answers resolve(bytes,bytes) with the result of resolve for the node of the call.
+func universalResolver(resolve func(node common.Hash, data []byte) ([]byte, error)) func([]byte) ([]byte, error) {
+	return func(input []byte) ([]byte, error) {
+		var name, data []byte
+		if err := universalResolveFunc.DecodeArgs(input, &name, &data); err != nil {
+			return nil, err
+		}
+
+		result, err := resolve(common.BytesToHash(data[4:36]), data)
+		if err != nil {
+			return nil, err
+		}
+		return universalResolveFunc.Returns.Pack(result, testResolver)
+	}
+}
+
+func TestReverseResolveVerifiesForward(t *testing.T) {
+	var (
+		alice = common.HexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+		bob   = common.HexToAddress("0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+	)
+
+	e := newTestENS(t, withNames(t, testChain{},
+		map[string]common.Address{"alice.eth": alice},
+		map[common.Address]string{alice: "alice.eth", bob: "alice.eth"},
+	))
+
+	name, err := e.ReverseResolve(t.Context(), alice)
+	if err != nil || name != "alice.eth" {
+		t.Errorf("ReverseResolve(alice) = %q, %v, want alice.eth", name, err)
+	}
+
+	// Anyone can set any name as their primary name, bob's does not resolve to bob.
+	if name, err := e.ReverseResolve(t.Context(), bob); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("ReverseResolve(bob) = %q, %v, want an unverified name rejected", name, err)
+	}
+}
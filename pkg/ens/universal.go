@@ -0,0 +1,362 @@
+package ens
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	goens "github.com/grassrootseconomics/go-ens/v3"
+	"github.com/lmittmann/w3"
+)
+
+const (
+	// DefaultUniversalResolver is the ENS Universal Resolver on mainnet.
+	DefaultUniversalResolver = "0xce01f8eee7E479C928F8919abD53E553a36CeF67"
+
+	// CoinTypeETH is the ENSIP-9 coin type of Ethereum, coin types from 0x80000000 up are EVM chains (ENSIP-11).
+	CoinTypeETH  = 60
+	evmCoinTypes = 0x80000000
+
+	// maxOffchainLookups bounds the CCIP-Read round trips of a single call.
+	maxOffchainLookups     = 4
+	maxGatewayResponseSize = 1 << 20
+)
+
+var (
+	universalResolveFunc = w3.MustNewFunc("resolve(bytes,bytes)", "bytes,address")
+	// https://eips.ethereum.org/EIPS/eip-3668
+	offchainLookupError = w3.MustNewFunc("OffchainLookup(address,string[],bytes,bytes4,bytes)", "")
+	callbackArgs        = abi.Arguments{
+		{Type: abi.Type{T: abi.BytesTy}},
+		{Type: abi.Type{T: abi.BytesTy}},
+	}
+
+	// Errors of the Universal Resolver when the resolver of a name fails a call, e.g. for a record it does not
+	// support.
+	resolverErrors = []*w3.Func{
+		w3.MustNewFunc("ResolverError(bytes)", ""),
+		w3.MustNewFunc("UnsupportedResolverProfile(bytes4)", ""),
+	}
+
+	addrFunc        = w3.MustNewFunc("addr(bytes32)", "address")
+	multicoinFunc   = w3.MustNewFunc("addr(bytes32,uint256)", "bytes")
+	textFunc        = w3.MustNewFunc("text(bytes32,string)", "string")
+	contenthashFunc = w3.MustNewFunc("contenthash(bytes32)", "bytes")
+)
+
+// resolveUniversal makes a resolver call for a name through the Universal Resolver, which finds the resolver of
+// the name including wildcard (ENSIP-10) resolvers. It returns the ABI encoded result of the call.
+func (e *ENS) resolveUniversal(ctx context.Context, backend bind.ContractBackend, name string, data []byte) ([]byte, error) {
+	input, err := universalResolveFunc.EncodeArgs(goens.DNSWireFormat(name), data)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := e.ccipReadCall(ctx, backend, e.universalResolver, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		result   []byte
+		resolver common.Address
+	)
+	if err := universalResolveFunc.DecodeReturns(output, &result, &resolver); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// resolveAddress returns the address of a name for a coin type, EVM addresses in checksum form and others hex
+// encoded. It is empty when the name has no address for the coin type.
+func (e *ENS) resolveAddress(ctx context.Context, backend bind.ContractBackend, name string, coinType uint64) (string, error) {
+	node, err := goens.NameHash(name)
+	if err != nil {
+		return "", err
+	}
+
+	if coinType == CoinTypeETH {
+		data, err := addrFunc.EncodeArgs(common.Hash(node))
+		if err != nil {
+			return "", err
+		}
+		result, err := e.resolveUniversal(ctx, backend, name, data)
+		if err != nil {
+			return "", err
+		}
+
+		var address common.Address
+		if err := addrFunc.DecodeReturns(result, &address); err != nil {
+			return "", err
+		}
+		if address == (common.Address{}) {
+			return "", nil
+		}
+		return address.Hex(), nil
+	}
+
+	data, err := multicoinFunc.EncodeArgs(common.Hash(node), new(big.Int).SetUint64(coinType))
+	if err != nil {
+		return "", err
+	}
+	result, err := e.resolveUniversal(ctx, backend, name, data)
+	if err != nil {
+		return "", err
+	}
+
+	var address []byte
+	if err := multicoinFunc.DecodeReturns(result, &address); err != nil {
+		return "", err
+	}
+	if len(address) == 0 {
+		return "", nil
+	}
+	if coinType >= evmCoinTypes && len(address) == common.AddressLength {
+		return common.BytesToAddress(address).Hex(), nil
+	}
+	return hexutil.Encode(address), nil
+}
+
+func (e *ENS) resolveText(ctx context.Context, backend bind.ContractBackend, name string, key string) (string, error) {
+	node, err := goens.NameHash(name)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := textFunc.EncodeArgs(common.Hash(node), key)
+	if err != nil {
+		return "", err
+	}
+	result, err := e.resolveUniversal(ctx, backend, name, data)
+	if err != nil {
+		return "", err
+	}
+
+	var value string
+	if err := textFunc.DecodeReturns(result, &value); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+func (e *ENS) resolveContenthash(ctx context.Context, backend bind.ContractBackend, name string) ([]byte, error) {
+	node, err := goens.NameHash(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := contenthashFunc.EncodeArgs(common.Hash(node))
+	if err != nil {
+		return nil, err
+	}
+	result, err := e.resolveUniversal(ctx, backend, name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var contenthash []byte
+	if err := contenthashFunc.DecodeReturns(result, &contenthash); err != nil {
+		return nil, err
+	}
+
+	return contenthash, nil
+}
+
+// ccipReadCall calls a contract and follows the CCIP-Read (EIP-3668) lookups it reverts with.
+func (e *ENS) ccipReadCall(ctx context.Context, backend bind.ContractBackend, to common.Address, input []byte) ([]byte, error) {
+	for range maxOffchainLookups {
+		output, err := backend.CallContract(ctx, ethereum.CallMsg{
+			To:   &to,
+			Data: input,
+		}, nil)
+		if err == nil {
+			return output, nil
+		}
+
+		data, ok := revertData(err)
+		if !ok || len(data) < 4 || !bytes.Equal(data[:4], offchainLookupError.Selector[:]) {
+			return nil, err
+		}
+
+		var (
+			sender    common.Address
+			urls      []string
+			callData  []byte
+			callback  [4]byte
+			extraData []byte
+		)
+		if err := offchainLookupError.DecodeArgs(data, &sender, &urls, &callData, &callback, &extraData); err != nil {
+			return nil, err
+		}
+		if sender != to {
+			return nil, fmt.Errorf("offchain lookup sender %s is not %s", sender.Hex(), to.Hex())
+		}
+
+		response, err := e.fetchGateway(ctx, urls, sender, callData)
+		if err != nil {
+			return nil, err
+		}
+
+		args, err := callbackArgs.Pack(response, extraData)
+		if err != nil {
+			return nil, err
+		}
+		input = append(callback[:], args...)
+	}
+
+	return nil, errors.New("too many offchain lookups")
+}
+
+// fetchGateway asks the gateways of an offchain lookup in order. A gateway answering with a client error ends
+// the lookup, gateways that fail otherwise are skipped and the lookup is unavailable when all of them do.
+func (e *ENS) fetchGateway(ctx context.Context, urls []string, sender common.Address, callData []byte) ([]byte, error) {
+	var (
+		senderHex = strings.ToLower(sender.Hex())
+		dataHex   = hexutil.Encode(callData)
+		lastErr   = errors.New("no gateway URLs")
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	for _, url := range urls {
+		url = strings.ReplaceAll(url, "{sender}", senderHex)
+		if !strings.HasPrefix(url, "https://") {
+			lastErr = fmt.Errorf("gateway %s is not https", url)
+			continue
+		}
+
+		var (
+			req *http.Request
+			err error
+		)
+		if strings.Contains(url, "{data}") {
+			req, err = http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(url, "{data}", dataHex), nil)
+		} else {
+			body, _ := json.Marshal(map[string]string{
+				"data":   dataHex,
+				"sender": senderHex,
+			})
+			req, err = http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+			if err == nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		resp, err := e.httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var gatewayResp struct {
+			Data string `json:"data"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, maxGatewayResponseSize)).Decode(&gatewayResp)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			return nil, fmt.Errorf("gateway %s: status %d", url, resp.StatusCode)
+		}
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("gateway %s: status %d", url, resp.StatusCode)
+			continue
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("gateway %s: %w", url, err)
+			continue
+		}
+
+		response, err := hexutil.Decode(gatewayResp.Data)
+		if err != nil {
+			lastErr = fmt.Errorf("gateway %s: %w", url, err)
+			continue
+		}
+
+		return response, nil
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrUnavailable, lastErr)
+}
+
+func revertData(err error) ([]byte, bool) {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil, false
+	}
+
+	s, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return nil, false
+	}
+
+	data, err := hexutil.Decode(s)
+	return data, err == nil
+}
+
+// resolverUnsupported reports whether err is the resolver of a name failing a call, as opposed to the name
+// having no resolver or the call not reaching it.
+func resolverUnsupported(err error) bool {
+	data, ok := revertData(err)
+	if !ok || len(data) < 4 {
+		return false
+	}
+
+	for _, resolverErr := range resolverErrors {
+		if bytes.Equal(data[:4], resolverErr.Selector[:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newGatewayClient returns an HTTP client that only connects to public addresses. Gateway URLs are chosen by
+// whoever controls the resolver of a name, they must not reach the network the resolver runs in.
+func newGatewayClient() *http.Client {
+	dialer := &net.Dialer{
+		Control: func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+				return fmt.Errorf("gateway address %s is not public", ip)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &http.Client{Transport: transport}
+}
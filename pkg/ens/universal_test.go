@@ -0,0 +1,98 @@
+package ens
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	goens "github.com/grassrootseconomics/go-ens/v3"
+	"github.com/lmittmann/w3"
+)
+
+func TestResolveRecords(t *testing.T) {
+	const celoCoinType = 2147525868
+
+	var (
+		address     = common.HexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+		celoAddress = common.HexToAddress("0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+		btcScript   = []byte{0x00, 0x14, 0xde, 0xad, 0xbe, 0xef}
+		contenthash = []byte{0xe3, 0x01, 0x01, 0x70, 0x12, 0x20}
+	)
+
+	node, err := goens.NameHash("alice.eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsupported := revert(w3.MustNewFunc("UnsupportedResolverProfile(bytes4)", "").Selector[:])
+
+	e := newTestENS(t, testChain{
+		common.HexToAddress(DefaultUniversalResolver): universalResolver(func(n common.Hash, data []byte) ([]byte, error) {
+			if n != node {
+				return nil, revert(w3.MustNewFunc("ResolverNotFound(bytes)", "").Selector[:])
+			}
+
+			switch {
+			case bytes.Equal(data[:4], contenthashFunc.Selector[:]):
+				return contenthashFunc.Returns.Pack(contenthash)
+			case bytes.Equal(data[:4], addrFunc.Selector[:]):
+				return addrFunc.Returns.Pack(address)
+			case bytes.Equal(data[:4], multicoinFunc.Selector[:]):
+				var coinType *big.Int
+				if err := multicoinFunc.DecodeArgs(data, &n, &coinType); err != nil {
+					return nil, err
+				}
+				switch coinType.Uint64() {
+				case celoCoinType:
+					return multicoinFunc.Returns.Pack(celoAddress.Bytes())
+				case 0:
+					return multicoinFunc.Returns.Pack(btcScript)
+				}
+				return multicoinFunc.Returns.Pack([]byte{})
+			case bytes.Equal(data[:4], textFunc.Selector[:]):
+				var key string
+				if err := textFunc.DecodeArgs(data, &n, &key); err != nil {
+					return nil, err
+				}
+				switch key {
+				case "url":
+					return textFunc.Returns.Pack("https://example.com")
+				case "com.github":
+					return nil, unsupported
+				}
+				return textFunc.Returns.Pack("")
+			}
+			return nil, unsupported
+		}),
+	})
+
+	records, err := e.ResolveRecords(t.Context(), "alice.eth", []uint64{CoinTypeETH, celoCoinType, 0, 501}, []string{"url", "com.github", "avatar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantAddresses := map[uint64]string{
+		CoinTypeETH:  address.Hex(),
+		celoCoinType: celoAddress.Hex(),
+		0:            "0x0014deadbeef",
+	}
+	if len(records.Addresses) != len(wantAddresses) {
+		t.Errorf("addresses = %v, want %v", records.Addresses, wantAddresses)
+	}
+	for coinType, want := range wantAddresses {
+		if got := records.Addresses[coinType]; got != want {
+			t.Errorf("address of coin type %d = %q, want %q", coinType, got, want)
+		}
+	}
+	if !bytes.Equal(records.Contenthash, contenthash) {
+		t.Errorf("contenthash = %x, want %x", records.Contenthash, contenthash)
+	}
+	if len(records.Texts) != 1 || records.Texts["url"] != "https://example.com" {
+		t.Errorf("texts = %v, want only the url, unset and unsupported records left out", records.Texts)
+	}
+
+	if _, err := e.ResolveRecords(t.Context(), "unknown.eth", []uint64{CoinTypeETH}, nil); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("ResolveRecords(unknown.eth) error = %v, want the missing resolver reported", err)
+	}
+}
@@ -0,0 +1,151 @@
+package ens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type (
+	endpoint struct {
+		url     string
+		client  *ethclient.Client
+		healthy bool
+	}
+
+	// callBackend binds a context to the contract calls go-ens makes without one. It remembers whether the
+	// endpoint failed to answer, as opposed to answering with an error such as a revert.
+	callBackend struct {
+		*ethclient.Client
+		ctx    context.Context
+		failed error
+	}
+)
+
+var (
+	// ErrUnavailable is returned when no RPC endpoint answered in time.
+	ErrUnavailable = errors.New("no RPC endpoint available")
+	// ErrUpstream is returned when an https or IPFS server failed to answer, e.g. with a timeout or a 5xx.
+	ErrUpstream = errors.New("upstream request failed")
+)
+
+func (b *callBackend) CodeAt(_ context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	code, err := b.Client.CodeAt(b.ctx, contract, blockNumber)
+	b.check(err)
+	return code, err
+}
+
+func (b *callBackend) CallContract(_ context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	output, err := b.Client.CallContract(b.ctx, call, blockNumber)
+	b.check(err)
+	return output, err
+}
+
+func (b *callBackend) check(err error) {
+	var rpcErr rpc.Error
+	if err != nil && !errors.As(err, &rpcErr) {
+		b.failed = err
+	}
+}
+
+// call runs fn against the endpoints in order until one answers, healthy endpoints are tried first. Every
+// attempt is bounded by the timeout of the provider.
+func call[T any](ctx context.Context, e *ENS, fn func(bind.ContractBackend) (T, error)) (T, error) {
+	var (
+		zero    T
+		lastErr error
+	)
+
+	for _, ep := range e.orderedEndpoints() {
+		attemptCtx, cancel := context.WithTimeout(ctx, e.timeout)
+		backend := &callBackend{Client: ep.client, ctx: attemptCtx}
+		v, err := fn(backend)
+		cancel()
+
+		if backend.failed == nil {
+			return v, err
+		}
+
+		lastErr = backend.failed
+		// The caller gave up, which says nothing about the endpoint.
+		if ctx.Err() != nil {
+			break
+		}
+		e.setHealthy(ep, false, backend.failed)
+	}
+
+	return zero, fmt.Errorf("%w: %v", ErrUnavailable, lastErr)
+}
+
+// orderedEndpoints returns the healthy endpoints in configured order followed by the unhealthy ones, which are
+// only tried as a last resort.
+func (e *ENS) orderedEndpoints() []*endpoint {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ordered := make([]*endpoint, 0, len(e.endpoints))
+	for _, ep := range e.endpoints {
+		if ep.healthy {
+			ordered = append(ordered, ep)
+		}
+	}
+	for _, ep := range e.endpoints {
+		if !ep.healthy {
+			ordered = append(ordered, ep)
+		}
+	}
+
+	return ordered
+}
+
+// setHealthy records the health of an endpoint and reports whether it changed.
+func (e *ENS) setHealthy(ep *endpoint, healthy bool, err error) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if ep.healthy == healthy {
+		return false
+	}
+	ep.healthy = healthy
+
+	if healthy {
+		e.logg.Info("RPC endpoint recovered", "url", ep.url)
+	} else {
+		e.logg.Warn("RPC endpoint failed", "url", ep.url, "error", err)
+	}
+
+	return true
+}
+
+// CheckHealth asks every endpoint for the latest block number, endpoints that answer are put back into
+// rotation. It returns how many endpoints changed health and fails when none is healthy, it is meant to run as
+// a sweeper job.
+func (e *ENS) CheckHealth(ctx context.Context) (int64, error) {
+	var changed, healthy int64
+
+	for _, ep := range e.endpoints {
+		checkCtx, cancel := context.WithTimeout(ctx, e.timeout)
+		_, err := ep.client.BlockNumber(checkCtx)
+		cancel()
+
+		if e.setHealthy(ep, err == nil, err) {
+			changed++
+		}
+		if err == nil {
+			healthy++
+		}
+	}
+
+	if healthy == 0 {
+		return changed, ErrUnavailable
+	}
+
+	return changed, nil
+}
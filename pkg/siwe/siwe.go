@@ -0,0 +1,234 @@
+// Package siwe parses, formats and verifies EIP-4361 Sign-In with Ethereum messages.
+package siwe
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type Message struct {
+	Domain         string
+	Address        common.Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+const (
+	headerSuffix = " wants you to sign in with your Ethereum account:"
+
+	uriTag            = "URI: "
+	versionTag        = "Version: "
+	chainIDTag        = "Chain ID: "
+	nonceTag          = "Nonce: "
+	issuedAtTag       = "Issued At: "
+	expirationTimeTag = "Expiration Time: "
+	notBeforeTag      = "Not Before: "
+	requestIDTag      = "Request ID: "
+	resourcesTag      = "Resources:"
+)
+
+var (
+	ErrMalformedMessage = errors.New("malformed SIWE message")
+	ErrExpired          = errors.New("SIWE message has expired")
+	ErrNotYetValid      = errors.New("SIWE message is not yet valid")
+)
+
+// Parse reads a message in the EIP-4361 text format.
+func Parse(raw string) (*Message, error) {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	if len(lines) < 9 {
+		return nil, ErrMalformedMessage
+	}
+
+	m := &Message{}
+
+	domain, ok := strings.CutSuffix(lines[0], headerSuffix)
+	if !ok || domain == "" {
+		return nil, fmt.Errorf("%w: invalid header", ErrMalformedMessage)
+	}
+	m.Domain = domain
+
+	if !common.IsHexAddress(lines[1]) {
+		return nil, fmt.Errorf("%w: invalid address", ErrMalformedMessage)
+	}
+	m.Address = common.HexToAddress(lines[1])
+
+	if lines[2] != "" {
+		return nil, ErrMalformedMessage
+	}
+
+	i := 3
+	if lines[i] != "" {
+		m.Statement = lines[i]
+		i++
+	}
+	if lines[i] != "" {
+		return nil, ErrMalformedMessage
+	}
+	i++
+
+	next := func(tag string, required bool) (string, error) {
+		if i < len(lines) && strings.HasPrefix(lines[i], tag) {
+			v := strings.TrimPrefix(lines[i], tag)
+			i++
+			return v, nil
+		}
+		if required {
+			return "", fmt.Errorf("%w: missing %q", ErrMalformedMessage, strings.TrimSpace(tag))
+		}
+		return "", nil
+	}
+
+	var err error
+	if m.URI, err = next(uriTag, true); err != nil {
+		return nil, err
+	}
+	if m.Version, err = next(versionTag, true); err != nil {
+		return nil, err
+	}
+	if m.Version != "1" {
+		return nil, fmt.Errorf("%w: unsupported version", ErrMalformedMessage)
+	}
+
+	chainID, err := next(chainIDTag, true)
+	if err != nil {
+		return nil, err
+	}
+	if m.ChainID, err = strconv.ParseInt(chainID, 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: invalid chain id", ErrMalformedMessage)
+	}
+
+	if m.Nonce, err = next(nonceTag, true); err != nil {
+		return nil, err
+	}
+	if len(m.Nonce) < 8 {
+		return nil, fmt.Errorf("%w: nonce too short", ErrMalformedMessage)
+	}
+
+	issuedAt, err := next(issuedAtTag, true)
+	if err != nil {
+		return nil, err
+	}
+	if m.IssuedAt, err = time.Parse(time.RFC3339, issuedAt); err != nil {
+		return nil, fmt.Errorf("%w: invalid issued at", ErrMalformedMessage)
+	}
+
+	for _, field := range []struct {
+		tag    string
+		target **time.Time
+	}{
+		{expirationTimeTag, &m.ExpirationTime},
+		{notBeforeTag, &m.NotBefore},
+	} {
+		v, _ := next(field.tag, false)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %q", ErrMalformedMessage, strings.TrimSpace(field.tag))
+		}
+		*field.target = &t
+	}
+
+	m.RequestID, _ = next(requestIDTag, false)
+
+	if i < len(lines) && lines[i] == resourcesTag {
+		i++
+		for ; i < len(lines) && strings.HasPrefix(lines[i], "- "); i++ {
+			m.Resources = append(m.Resources, strings.TrimPrefix(lines[i], "- "))
+		}
+	}
+
+	if i < len(lines) && !(i == len(lines)-1 && lines[i] == "") {
+		return nil, fmt.Errorf("%w: unexpected trailing content", ErrMalformedMessage)
+	}
+
+	return m, nil
+}
+
+// String formats the message in the EIP-4361 text format, this is the exact text a wallet signs.
+func (m *Message) String() string {
+	var b strings.Builder
+
+	b.WriteString(m.Domain + headerSuffix + "\n")
+	b.WriteString(m.Address.Hex() + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(uriTag + m.URI + "\n")
+	b.WriteString(versionTag + m.Version + "\n")
+	b.WriteString(chainIDTag + strconv.FormatInt(m.ChainID, 10) + "\n")
+	b.WriteString(nonceTag + m.Nonce + "\n")
+	b.WriteString(issuedAtTag + m.IssuedAt.UTC().Format(time.RFC3339))
+	if m.ExpirationTime != nil {
+		b.WriteString("\n" + expirationTimeTag + m.ExpirationTime.UTC().Format(time.RFC3339))
+	}
+	if m.NotBefore != nil {
+		b.WriteString("\n" + notBeforeTag + m.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if m.RequestID != "" {
+		b.WriteString("\n" + requestIDTag + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\n" + resourcesTag)
+		for _, r := range m.Resources {
+			b.WriteString("\n- " + r)
+		}
+	}
+
+	return b.String()
+}
+
+// ValidAt checks the expiration and not before bounds of the message.
+func (m *Message) ValidAt(t time.Time) error {
+	if m.ExpirationTime != nil && !t.Before(*m.ExpirationTime) {
+		return ErrExpired
+	}
+	if m.NotBefore != nil && t.Before(*m.NotBefore) {
+		return ErrNotYetValid
+	}
+
+	return nil
+}
+
+// Hash returns the EIP-191 personal_sign digest of the raw message.
+func Hash(raw string) common.Hash {
+	return common.BytesToHash(accounts.TextHash([]byte(raw)))
+}
+
+// RecoverAddress returns the EOA that produced sig over the raw message.
+func RecoverAddress(raw string, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("invalid signature length %d", len(sig))
+	}
+
+	normalized := make([]byte, crypto.SignatureLength)
+	copy(normalized, sig)
+	if normalized[crypto.RecoveryIDOffset] >= 27 {
+		normalized[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(Hash(raw).Bytes(), normalized)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*pub), nil
+}
@@ -0,0 +1,90 @@
+package siwe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	expires := time.Date(2026, 1, 1, 0, 10, 0, 0, time.UTC)
+	msg := &Message{
+		Domain:         "sarafu.network",
+		Statement:      "Rename alice.sarafu.eth to bob.sarafu.eth",
+		URI:            "https://sarafu.network",
+		Version:        "1",
+		ChainID:        1,
+		Nonce:          "32891756a1b2c3d4",
+		IssuedAt:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExpirationTime: &expires,
+		Resources:      []string{"https://sarafu.network/terms"},
+	}
+
+	for _, statement := range []string{msg.Statement, ""} {
+		msg.Statement = statement
+		parsed, err := Parse(msg.String())
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", msg.String(), err)
+		}
+		if parsed.String() != msg.String() {
+			t.Errorf("round trip mismatch:\n%s\n---\n%s", parsed.String(), msg.String())
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "empty",
+			input: "",
+		},
+		{
+			name:  "missing nonce",
+			input: "sarafu.network wants you to sign in with your Ethereum account:\n0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045\n\n\nURI: https://sarafu.network\nVersion: 1\nChain ID: 1\nIssued At: 2026-01-01T00:00:00Z\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.input); !errors.Is(err, ErrMalformedMessage) {
+				t.Errorf("Parse() error = %v, want %v", err, ErrMalformedMessage)
+			}
+		})
+	}
+}
+
+func TestRecoverAddress(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &Message{
+		Domain:   "sarafu.network",
+		Address:  crypto.PubkeyToAddress(key.PublicKey),
+		URI:      "https://sarafu.network",
+		Version:  "1",
+		ChainID:  1,
+		Nonce:    "32891756a1b2c3d4",
+		IssuedAt: time.Now(),
+	}
+
+	sig, err := crypto.Sign(Hash(msg.String()).Bytes(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[64] += 27
+
+	recovered, err := RecoverAddress(msg.String(), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != msg.Address {
+		t.Errorf("RecoverAddress() = %s, want %s", recovered.Hex(), msg.Address.Hex())
+	}
+}
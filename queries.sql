@@ -1,33 +1,594 @@
 --name: register-name
 -- $1: primary_name
 -- $2: blockchain_address
+-- $3: kind
+-- $4: lease in seconds, the name never expires when NULL
+-- $5: tenant
+-- The registering address owns the name and the first active name of an address becomes its primary name
 INSERT INTO alias(
     primary_name,
-    blockchain_address
-) VALUES($1, $2)
+    blockchain_address,
+    owner,
+    kind,
+    expires_at,
+    is_primary,
+    tenant
+) VALUES($1, $2, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4), NOT EXISTS (
+    SELECT 1 FROM alias WHERE blockchain_address = $2 AND is_primary = true AND active = true
+), NULLIF($5::TEXT, ''))
 
---name: update-name
+--name: lock-primary-alias
+-- $1: blockchain_address
+SELECT id, primary_name, owner FROM alias
+WHERE blockchain_address = $1 AND is_primary = true AND active = true
+FOR UPDATE
+
+--name: lock-alias-by-name
 -- $1: primary_name
--- $2: blockchain-address
-UPDATE alias SET 
-    primary_name = $1,
+SELECT id, primary_name, owner FROM alias WHERE primary_name = $1 AND active = true FOR UPDATE
+
+--name: lock-owner-renames
+-- $1: owner
+-- Serializes the renames of an owner so that concurrent renames of two of its names cannot both pass the cooldown
+SELECT pg_advisory_xact_lock(hashtextextended($1, 0))
+
+--name: rename-alias
+-- $1: id
+-- $2: new primary_name
+-- $3: cooldown in seconds
+-- $4: owner, the cooldown covers all of its names
+UPDATE alias SET
+    primary_name = $2,
+    renamed_at = CURRENT_TIMESTAMP,
     updated_at = CURRENT_TIMESTAMP
-WHERE blockchain_address = $2 AND active = true
+WHERE id = $1 AND NOT EXISTS (
+    SELECT 1 FROM alias renamed
+    WHERE renamed.owner = $4 AND renamed.renamed_at > CURRENT_TIMESTAMP - make_interval(secs => $3)
+)
+
+--name: create-redirect
+-- $1: old primary_name
+-- $2: alias_id
+-- $3: grace period in seconds
+INSERT INTO alias_redirect(
+    old_name,
+    alias_id,
+    expires_at
+) VALUES($1, $2, CURRENT_TIMESTAMP + make_interval(secs => $3))
+ON CONFLICT (old_name)
+DO UPDATE SET
+    alias_id = EXCLUDED.alias_id,
+    created_at = CURRENT_TIMESTAMP,
+    expires_at = EXCLUDED.expires_at
+
+--name: reclaim-redirect
+-- $1: old primary_name
+-- $2: alias_id
+DELETE FROM alias_redirect WHERE old_name = $1 AND alias_id = $2
+
+--name: purge-expired-redirects
+DELETE FROM alias_redirect WHERE expires_at <= CURRENT_TIMESTAMP
 
 --name: lookup-name
 -- $1: primary_name
-SELECT blockchain_address FROM alias WHERE primary_name = $1 AND active = true
+-- $2: expiry grace period in seconds
+-- Old names resolve to the renamed alias until their redirect expires, expired names until their grace period ends
+SELECT blockchain_address FROM alias
+WHERE primary_name = $1 AND active = true
+AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP - make_interval(secs => $2))
+UNION ALL
+SELECT alias.blockchain_address FROM alias_redirect
+INNER JOIN alias ON alias_redirect.alias_id = alias.id
+WHERE alias_redirect.old_name = $1 AND alias_redirect.expires_at > CURRENT_TIMESTAMP AND alias.active = true
+AND (alias.expires_at IS NULL OR alias.expires_at > CURRENT_TIMESTAMP - make_interval(secs => $2))
+LIMIT 1
+
+--name: lookup-name-record
+-- $1: primary_name
+-- $2: expiry grace period in seconds
+SELECT primary_name, blockchain_address, owner, kind, is_primary, expires_at, COALESCE(expires_at <= CURRENT_TIMESTAMP, false), created_at, updated_at
+FROM alias
+WHERE primary_name = $1 AND active = true
+AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP - make_interval(secs => $2))
+UNION ALL
+SELECT alias.primary_name, alias.blockchain_address, alias.owner, alias.kind, alias.is_primary, alias.expires_at, COALESCE(alias.expires_at <= CURRENT_TIMESTAMP, false), alias.created_at, alias.updated_at
+FROM alias_redirect
+INNER JOIN alias ON alias_redirect.alias_id = alias.id
+WHERE alias_redirect.old_name = $1 AND alias_redirect.expires_at > CURRENT_TIMESTAMP AND alias.active = true
+AND (alias.expires_at IS NULL OR alias.expires_at > CURRENT_TIMESTAMP - make_interval(secs => $2))
+LIMIT 1
+
+--name: renew-name
+-- $1: primary_name
+-- $2: lease in seconds
+-- Renewals extend from the current expiry, or from now once it has passed. Names without expiry are left alone.
+UPDATE alias SET
+    expires_at = GREATEST(expires_at, CURRENT_TIMESTAMP) + make_interval(secs => $2),
+    updated_at = CURRENT_TIMESTAMP
+WHERE primary_name = $1 AND active = true AND expires_at IS NOT NULL
+RETURNING expires_at
+
+--name: release-expired-names
+-- $1: expiry grace period in seconds
+UPDATE alias SET
+    active = false,
+    is_primary = false,
+    updated_at = CURRENT_TIMESTAMP
+WHERE active = true AND expires_at <= CURRENT_TIMESTAMP - make_interval(secs => $1)
+RETURNING blockchain_address
+
+--name: lock-name
+-- $1: primary_name
+SELECT blockchain_address, owner FROM alias WHERE primary_name = $1 AND active = true FOR UPDATE
+
+--name: set-name-address
+-- $1: primary_name
+-- $2: blockchain_address
+UPDATE alias SET
+    blockchain_address = $2,
+    is_primary = NOT EXISTS (
+        SELECT 1 FROM alias other
+        WHERE other.blockchain_address = $2 AND other.is_primary = true AND other.active = true AND other.primary_name <> $1
+    ),
+    updated_at = CURRENT_TIMESTAMP
+WHERE primary_name = $1 AND active = true
+
+--name: promote-primary-name
+-- $1: blockchain_address
+-- Gives an address that lost its primary name its oldest remaining name as primary
+UPDATE alias SET
+    is_primary = true,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = (
+    SELECT id FROM alias WHERE blockchain_address = $1 AND active = true
+    ORDER BY created_at, id
+    LIMIT 1
+)
+AND NOT EXISTS (SELECT 1 FROM alias WHERE blockchain_address = $1 AND is_primary = true AND active = true)
 
 --name: reverse-lookup
 -- $1: blockchain_address
-SELECT primary_name FROM alias WHERE blockchain_address = $1 AND active = true
+-- $2: expiry grace period in seconds
+SELECT primary_name FROM alias
+WHERE blockchain_address = $1 AND is_primary = true AND active = true
+AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP - make_interval(secs => $2))
 
---name: upsert-name
+--name: batch-lookup-names
+-- $1: primary_names
+-- $2: expiry grace period in seconds
+-- Same rules as lookup-name, a current name wins over a redirect of the same name
+SELECT DISTINCT ON (requested_name) requested_name, blockchain_address FROM (
+    SELECT primary_name AS requested_name, blockchain_address, 0 AS precedence FROM alias
+    WHERE primary_name = ANY($1) AND active = true
+    AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP - make_interval(secs => $2))
+    UNION ALL
+    SELECT alias_redirect.old_name, alias.blockchain_address, 1 FROM alias_redirect
+    INNER JOIN alias ON alias_redirect.alias_id = alias.id
+    WHERE alias_redirect.old_name = ANY($1) AND alias_redirect.expires_at > CURRENT_TIMESTAMP AND alias.active = true
+    AND (alias.expires_at IS NULL OR alias.expires_at > CURRENT_TIMESTAMP - make_interval(secs => $2))
+) candidates
+ORDER BY requested_name, precedence
+
+--name: batch-reverse-lookup
+-- $1: blockchain_addresses
+-- $2: expiry grace period in seconds
+SELECT blockchain_address, primary_name FROM alias
+WHERE blockchain_address = ANY($1) AND is_primary = true AND active = true
+AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP - make_interval(secs => $2))
+
+--name: list-names
+-- $1: name prefix, LIKE wildcards escaped
+-- $2: name substring, LIKE wildcards escaped
+-- $3, $4: created_at range, inclusive start and exclusive end
+-- $5, $6: updated_at range, inclusive start and exclusive end
+-- $7: active, NULL for both
+-- $8: tenant
+-- $9: kind
+-- Empty strings and NULLs do not filter. The store appends the cursor condition, ORDER BY and LIMIT of the sort.
+SELECT
+    alias.id,
+    alias.primary_name,
+    alias.blockchain_address,
+    alias.owner,
+    alias.kind,
+    COALESCE(alias.tenant, ''),
+    alias.is_primary,
+    COALESCE(alias.active, false),
+    alias.expires_at,
+    alias.created_at,
+    alias.updated_at
+FROM alias
+WHERE ($1 = '' OR alias.primary_name LIKE $1 || '%')
+AND ($2 = '' OR alias.primary_name LIKE '%' || $2 || '%')
+AND ($3::TIMESTAMP IS NULL OR alias.created_at >= $3)
+AND ($4::TIMESTAMP IS NULL OR alias.created_at < $4)
+AND ($5::TIMESTAMP IS NULL OR alias.updated_at >= $5)
+AND ($6::TIMESTAMP IS NULL OR alias.updated_at < $6)
+AND ($7::BOOLEAN IS NULL OR COALESCE(alias.active, false) = $7)
+AND ($8 = '' OR alias.tenant = $8)
+AND ($9 = '' OR alias.kind = $9)
+
+--name: list-address-names
+-- $1: blockchain_address
+-- $2: kind, all kinds when empty
+SELECT primary_name, owner, kind, is_primary, created_at FROM alias
+WHERE blockchain_address = $1 AND active = true AND ($2 = '' OR kind = $2)
+ORDER BY is_primary DESC, created_at, id
+
+--name: clear-primary-name
+-- $1: blockchain_address
+UPDATE alias SET
+    is_primary = false,
+    updated_at = CURRENT_TIMESTAMP
+WHERE blockchain_address = $1 AND is_primary = true AND active = true
+
+--name: set-primary-name
 -- $1: primary_name
 -- $2: blockchain_address
-INSERT INTO alias(primary_name, blockchain_address) 
-VALUES($1, $2)
-ON CONFLICT (blockchain_address) 
-DO UPDATE SET 
-    primary_name = EXCLUDED.primary_name,
-    updated_at = CURRENT_TIMESTAMP
\ No newline at end of file
+UPDATE alias SET
+    is_primary = true,
+    updated_at = CURRENT_TIMESTAMP
+WHERE primary_name = $1 AND blockchain_address = $2 AND active = true
+
+--name: create-api-key
+-- $1: name
+-- $2: key_hash
+-- $3: scopes
+-- $4: expires_at
+INSERT INTO api_key(
+    name,
+    key_hash,
+    scopes,
+    expires_at
+) VALUES($1, $2, $3, $4)
+RETURNING id, created_at
+
+--name: list-api-keys
+SELECT id, name, scopes, active, created_at, expires_at, last_used_at FROM api_key ORDER BY id
+
+--name: revoke-api-key
+-- $1: id
+UPDATE api_key SET active = false WHERE id = $1 AND active = true
+
+--name: use-api-key
+-- $1: key_hash
+-- last_used_at is only written once a minute so that busy keys do not update their row on every request
+WITH used AS (
+    SELECT id, name, scopes, last_used_at FROM api_key
+    WHERE key_hash = $1 AND active = true AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+), touched AS (
+    UPDATE api_key SET
+        last_used_at = CURRENT_TIMESTAMP
+    FROM used
+    WHERE api_key.id = used.id AND (used.last_used_at IS NULL OR used.last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+)
+SELECT id, name, scopes FROM used
+
+
+--name: export-names
+SELECT
+    alias.primary_name,
+    alias.blockchain_address,
+    alias.owner,
+    alias.kind,
+    COALESCE(alias.tenant, ''),
+    alias.is_primary,
+    COALESCE((SELECT jsonb_object_agg(text_record.key, text_record.value) FROM text_record WHERE text_record.alias_id = alias.id), '{}'::jsonb),
+    alias.expires_at,
+    alias.created_at,
+    alias.updated_at
+FROM alias
+WHERE alias.active = true
+ORDER BY alias.id
+
+--name: has-names
+SELECT EXISTS (SELECT 1 FROM alias)
+
+--name: restore-name
+-- $1: primary_name
+-- $2: blockchain_address
+-- $3: owner
+-- $4: kind
+-- $5: is_primary
+-- $6: expires_at
+-- $7: created_at
+-- $8: updated_at
+-- $9: tenant
+INSERT INTO alias(
+    primary_name,
+    blockchain_address,
+    owner,
+    kind,
+    is_primary,
+    expires_at,
+    created_at,
+    updated_at,
+    tenant
+) VALUES($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9::TEXT, ''))
+
+--name: lookup-text-records
+-- $1: primary_name
+SELECT text_record.key, text_record.value FROM text_record
+INNER JOIN alias ON text_record.alias_id = alias.id
+WHERE alias.primary_name = $1 AND alias.active = true
+
+--name: set-text-record
+-- $1: primary_name
+-- $2: key
+-- $3: value
+INSERT INTO text_record(alias_id, key, value)
+SELECT id, $2, $3 FROM alias WHERE primary_name = $1 AND active = true
+ON CONFLICT (alias_id, key)
+DO UPDATE SET
+    value = EXCLUDED.value,
+    updated_at = CURRENT_TIMESTAMP
+
+--name: delete-text-record
+-- $1: primary_name
+-- $2: key
+DELETE FROM text_record USING alias
+WHERE text_record.alias_id = alias.id AND alias.primary_name = $1 AND alias.active = true AND text_record.key = $2
+
+--name: create-siwe-challenge
+-- $1: nonce
+-- $2: primary_name
+-- $3: signer
+-- $4: statement
+-- $5: action
+-- $6: ttl in seconds
+INSERT INTO siwe_challenge(
+    nonce,
+    primary_name,
+    signer,
+    statement,
+    action,
+    expires_at
+) VALUES($1, $2, $3, $4, $5, CURRENT_TIMESTAMP + make_interval(secs => $6))
+
+--name: consume-siwe-challenge
+-- $1: nonce
+-- $2: signer
+-- $3: statement
+UPDATE siwe_challenge SET used = true
+WHERE nonce = $1 AND signer = $2 AND statement = $3 AND used = false AND expires_at > CURRENT_TIMESTAMP
+RETURNING primary_name, action
+
+--name: purge-expired-siwe-challenges
+DELETE FROM siwe_challenge WHERE expires_at <= CURRENT_TIMESTAMP
+
+
+--name: claim-idempotency-key
+-- $1: subject
+-- $2: key
+-- $3: request_hash
+-- $4: window in seconds
+-- $5: lease in seconds, in flight claims older than it were abandoned
+INSERT INTO idempotency_key(
+    subject,
+    key,
+    request_hash
+) VALUES($1, $2, $3)
+ON CONFLICT (subject, key)
+DO UPDATE SET
+    request_hash = EXCLUDED.request_hash,
+    status_code = NULL,
+    response = NULL,
+    created_at = CURRENT_TIMESTAMP
+WHERE idempotency_key.created_at < CURRENT_TIMESTAMP - make_interval(secs => $4)
+OR (idempotency_key.status_code IS NULL AND idempotency_key.created_at < CURRENT_TIMESTAMP - make_interval(secs => $5))
+RETURNING true
+
+--name: lookup-idempotency-key
+-- $1: subject
+-- $2: key
+SELECT request_hash, COALESCE(status_code, 0), response FROM idempotency_key WHERE subject = $1 AND key = $2
+
+--name: complete-idempotency-key
+-- $1: subject
+-- $2: key
+-- $3: status_code
+-- $4: response
+UPDATE idempotency_key SET
+    status_code = $3,
+    response = $4
+WHERE subject = $1 AND key = $2
+
+--name: release-idempotency-key
+-- $1: subject
+-- $2: key
+DELETE FROM idempotency_key WHERE subject = $1 AND key = $2 AND status_code IS NULL
+
+--name: purge-idempotency-keys
+-- $1: window in seconds
+DELETE FROM idempotency_key WHERE created_at < CURRENT_TIMESTAMP - make_interval(secs => $1)
+
+
+--name: register-first-available
+-- $1: candidate primary_names in order of preference
+-- $2: blockchain_address
+-- $3: lease in seconds, the name never expires when NULL
+-- $4: tenant
+INSERT INTO alias(primary_name, blockchain_address, owner, expires_at, is_primary, tenant)
+SELECT c.candidate, $2, $2, CURRENT_TIMESTAMP + make_interval(secs => $3), NOT EXISTS (
+    SELECT 1 FROM alias WHERE blockchain_address = $2 AND is_primary = true AND active = true
+), NULLIF($4::TEXT, '') FROM unnest($1::TEXT[]) WITH ORDINALITY AS c(candidate, position)
+WHERE NOT EXISTS (SELECT 1 FROM alias WHERE alias.primary_name = c.candidate AND alias.active = true)
+AND NOT EXISTS (
+    SELECT 1 FROM alias_redirect
+    WHERE alias_redirect.old_name = c.candidate AND alias_redirect.expires_at > CURRENT_TIMESTAMP
+)
+AND NOT EXISTS (
+    SELECT 1 FROM name_reservation
+    WHERE name_reservation.primary_name = c.candidate AND name_reservation.expires_at > CURRENT_TIMESTAMP
+)
+ORDER BY c.position
+LIMIT 1
+ON CONFLICT (primary_name) WHERE active = true DO NOTHING
+RETURNING primary_name
+
+
+--name: taken-names
+-- $1: primary_names
+SELECT primary_name FROM alias WHERE primary_name = ANY($1) AND active = true
+UNION
+SELECT primary_name FROM name_reservation WHERE primary_name = ANY($1) AND expires_at > CURRENT_TIMESTAMP
+UNION
+SELECT old_name FROM alias_redirect WHERE old_name = ANY($1) AND expires_at > CURRENT_TIMESTAMP
+
+--name: name-reserved
+-- $1: primary_name
+-- $2: reservation token allowed to use the name
+-- Names in the grace period of a rename are held like reservations
+SELECT EXISTS (
+    SELECT 1 FROM name_reservation
+    WHERE primary_name = $1 AND expires_at > CURRENT_TIMESTAMP AND token IS DISTINCT FROM $2
+) OR EXISTS (
+    SELECT 1 FROM alias_redirect WHERE old_name = $1 AND expires_at > CURRENT_TIMESTAMP
+)
+
+--name: reserve-name
+-- $1: primary_name
+-- $2: token
+-- $3: subject
+-- $4: ttl in seconds
+INSERT INTO name_reservation(
+    primary_name,
+    token,
+    subject,
+    expires_at
+)
+SELECT $1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4)
+WHERE NOT EXISTS (SELECT 1 FROM alias WHERE primary_name = $1 AND active = true)
+AND NOT EXISTS (SELECT 1 FROM alias_redirect WHERE old_name = $1 AND expires_at > CURRENT_TIMESTAMP)
+ON CONFLICT (primary_name)
+DO UPDATE SET
+    token = EXCLUDED.token,
+    subject = EXCLUDED.subject,
+    created_at = CURRENT_TIMESTAMP,
+    expires_at = EXCLUDED.expires_at
+WHERE name_reservation.expires_at <= CURRENT_TIMESTAMP
+RETURNING expires_at
+
+--name: consume-reservation
+-- $1: primary_name
+-- $2: token
+DELETE FROM name_reservation
+WHERE primary_name = $1 AND token = $2 AND expires_at > CURRENT_TIMESTAMP
+RETURNING primary_name
+
+--name: release-reservation
+-- $1: token
+DELETE FROM name_reservation WHERE token = $1
+
+--name: purge-expired-reservations
+DELETE FROM name_reservation WHERE expires_at <= CURRENT_TIMESTAMP
+
+
+--name: list-policy-entries
+SELECT id, list, value, description, created_at FROM name_policy_entry ORDER BY id
+
+--name: add-policy-entry
+-- $1: list
+-- $2: value
+-- $3: description
+INSERT INTO name_policy_entry(
+    list,
+    value,
+    description
+) VALUES($1, $2, $3)
+RETURNING id, created_at
+
+--name: delete-policy-entry
+-- $1: id
+DELETE FROM name_policy_entry WHERE id = $1
+
+
+--name: create-transfer
+-- $1: alias_id
+-- $2: from_owner
+-- $3: to_address
+-- $4: initiated_by
+-- $5: ttl in seconds
+INSERT INTO name_transfer(
+    alias_id,
+    from_owner,
+    to_address,
+    initiated_by,
+    expires_at
+) VALUES($1, $2, $3, $4, CURRENT_TIMESTAMP + make_interval(secs => $5))
+RETURNING id, status, created_at, expires_at
+
+--name: lookup-transfer
+-- $1: id
+SELECT
+    name_transfer.id,
+    alias.primary_name,
+    name_transfer.from_owner,
+    name_transfer.to_address,
+    name_transfer.status,
+    name_transfer.initiated_by,
+    name_transfer.created_at,
+    name_transfer.expires_at,
+    name_transfer.completed_at
+FROM name_transfer
+INNER JOIN alias ON name_transfer.alias_id = alias.id
+WHERE name_transfer.id = $1
+
+--name: lock-pending-transfer
+-- $1: id
+SELECT alias_id, from_owner, to_address FROM name_transfer
+WHERE id = $1 AND status = 'pending' AND expires_at > CURRENT_TIMESTAMP
+FOR UPDATE
+
+--name: finish-transfer
+-- $1: id
+-- $2: status
+UPDATE name_transfer SET
+    status = $2,
+    completed_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status = 'pending'
+RETURNING alias_id
+
+--name: expire-transfers
+UPDATE name_transfer SET
+    status = 'expired',
+    completed_at = CURRENT_TIMESTAMP
+WHERE status = 'pending' AND expires_at <= CURRENT_TIMESTAMP
+
+--name: lock-alias-by-id
+-- $1: id
+SELECT id, primary_name, owner, blockchain_address FROM alias WHERE id = $1 AND active = true FOR UPDATE
+
+--name: move-alias
+-- $1: id
+-- $2: new owner and blockchain_address
+-- The rename cooldown belongs to the previous owner, the new owner starts without one.
+UPDATE alias SET
+    owner = $2,
+    blockchain_address = $2,
+    renamed_at = NULL,
+    is_primary = NOT EXISTS (
+        SELECT 1 FROM alias other
+        WHERE other.blockchain_address = $2 AND other.is_primary = true AND other.active = true AND other.id <> $1
+    ),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+
+--name: record-alias-event
+-- $1: alias_id
+-- $2: event
+-- $3: data
+-- $4: actor
+INSERT INTO alias_event(
+    alias_id,
+    event,
+    data,
+    actor
+) VALUES($1, $2, $3, $4)
+
+--name: list-alias-events
+-- $1: primary_name
+SELECT alias_event.event, alias_event.data, alias_event.actor, alias_event.created_at FROM alias_event
+INNER JOIN alias ON alias_event.alias_id = alias.id
+WHERE alias.primary_name = $1 AND alias.active = true
+ORDER BY alias_event.id